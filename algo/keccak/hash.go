@@ -6,7 +6,10 @@ import (
 	"github.com/gluk256/crypto/algo/primitives"
 )
 
-const Rate = 72
+const (
+	Rate = 72
+	Size = 64 // default digest size (see Sum)
+)
 
 type Keccak512 struct {
 	absorbing bool
@@ -84,15 +87,20 @@ func (k *Keccak512) read(dst []byte, xor bool) {
 	}
 }
 
-func (k *Keccak512) Read(dst []byte) {
+// implements io.Reader, never fails
+func (k *Keccak512) Read(dst []byte) (int, error) {
 	k.read(dst, false)
+	return len(dst), nil
 }
 
 func (k *Keccak512) ReadXor(dst []byte) {
 	k.read(dst, true)
 }
 
-func (k *Keccak512) Write(src []byte) {
+// implements io.Writer, never fails.
+// unlike standard hash functions, it is possible to write after read (the state is not reset).
+func (k *Keccak512) Write(src []byte) (int, error) {
+	n := len(src)
 	if !k.absorbing || k.buf == nil {
 		k.buf = k.storage[:0]
 	}
@@ -121,8 +129,37 @@ func (k *Keccak512) Write(src []byte) {
 			}
 		}
 	}
+	return n, nil
+}
+
+// appends [Size] bytes of output to the input, without changing the state of the sponge.
+// together with the following functions implements hash.Hash.
+func (k *Keccak512) Sum(in []byte) []byte {
+	dup := *k
+	if dup.buf == nil {
+		dup.Write(nil) // empty input must be finalized as well
+	} else {
+		// buf must point into the storage of the copy
+		if dup.absorbing {
+			dup.buf = dup.storage[:len(k.buf)]
+		} else {
+			dup.buf = dup.storage[Rate-len(k.buf):]
+		}
+	}
+	hash := make([]byte, Size)
+	dup.read(hash, false)
+	dup.Reset()
+	return append(in, hash...)
+}
+
+func (k *Keccak512) Reset() {
+	*k = Keccak512{}
 }
 
+func (k *Keccak512) Size() int { return Size }
+
+func (k *Keccak512) BlockSize() int { return Rate }
+
 func Digest(src []byte, sz int) []byte {
 	res := make([]byte, sz)
 	var k Keccak512
@@ -2,8 +2,11 @@ package keccak
 
 import (
 	"bytes"
+	"crypto/hmac"
 	"encoding/hex"
 	"fmt"
+	"hash"
+	"io"
 	mrand "math/rand"
 	"testing"
 	"time"
@@ -214,3 +217,57 @@ func TestReadSequence(t *testing.T) {
 		}
 	}
 }
+
+var _ hash.Hash = (*Keccak512)(nil)
+var _ io.ReadWriter = (*Keccak512)(nil)
+
+func TestHashInterface(t *testing.T) {
+	seed := time.Now().Unix()
+	mrand.Seed(seed)
+
+	for i, text := range input {
+		var k Keccak512
+		n, err := io.Copy(&k, bytes.NewReader([]byte(text)))
+		if err != nil || n != int64(len(text)) {
+			t.Fatalf("io.Copy failed: %d, %v", n, err)
+		}
+		s1 := k.Sum(nil)
+		s2 := k.Sum([]byte{0xff})
+		if hex.EncodeToString(s1) != expected[i] || !bytes.Equal(s1, s2[1:]) || s2[0] != 0xff {
+			t.Fatalf("failed test number %d, result: \n[%x]", i, s1)
+		}
+
+		// Sum must not change the state, even in the middle of reading
+		x := make([]byte, mrand.Intn(200)+1)
+		k.Read(x)
+		s1 = k.Sum(nil)
+		y := make([]byte, Size)
+		k.Read(y)
+		if !bytes.Equal(s1, y) {
+			t.Fatalf("Sum changed the state, test number %d, seed %d", i, seed)
+		}
+
+		k.Reset()
+		k.Write([]byte(text))
+		if hex.EncodeToString(k.Sum(nil)) != expected[i] {
+			t.Fatalf("Reset failed, test number %d", i)
+		}
+	}
+}
+
+func TestHmac(t *testing.T) {
+	key := []byte(input[1])
+	data := []byte(input[3])
+	mac := hmac.New(func() hash.Hash { return new(Keccak512) }, key)
+	mac.Write(data)
+	m1 := mac.Sum(nil)
+	mac.Reset()
+	mac.Write(data)
+	m2 := mac.Sum(nil)
+	if len(m1) != Size || !bytes.Equal(m1, m2) {
+		t.Fatalf("hmac failed: [%x] vs. [%x]", m1, m2)
+	}
+	if bytes.Equal(m1, Digest(data, Size)) {
+		t.Fatal("hmac ignores the key")
+	}
+}
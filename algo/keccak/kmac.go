@@ -0,0 +1,114 @@
+package keccak
+
+// cSHAKE and KMAC (NIST SP 800-185).
+// The function name N is reserved for NIST, the customization string S is used for domain separation.
+
+const dsbyteCShake = byte(0x04)
+
+func leftEncode(x uint64) []byte {
+	n := 1
+	for v := x >> 8; v > 0; v >>= 8 {
+		n++
+	}
+	b := make([]byte, n+1)
+	b[0] = byte(n)
+	for i := n; i > 0; i-- {
+		b[i] = byte(x)
+		x >>= 8
+	}
+	return b
+}
+
+func rightEncode(x uint64) []byte {
+	b := leftEncode(x)
+	n := b[0]
+	copy(b, b[1:])
+	b[len(b)-1] = n
+	return b
+}
+
+func encodeString(s []byte) []byte {
+	return append(leftEncode(uint64(len(s))*8), s...)
+}
+
+// prepends the encoded w, and pads the result with zeros to the multiple of w
+// (allocated at once, so that no stray copies of x are left in memory)
+func bytepad(x []byte, w int) []byte {
+	enc := leftEncode(uint64(w))
+	n := len(enc) + len(x)
+	if pad := n % w; pad != 0 {
+		n += w - pad
+	}
+	b := make([]byte, n)
+	copy(b, enc)
+	copy(b[len(enc):], x)
+	return b
+}
+
+func wipe(b []byte) {
+	for i := range b {
+		b[i] = 0
+	}
+}
+
+func newCShake(rate int, outputLen int, n []byte, s []byte) *Sponge {
+	if len(n) == 0 && len(s) == 0 {
+		return newSponge(rate, dsbyteShake, outputLen)
+	}
+	sp := newSponge(rate, dsbyteCShake, outputLen)
+	sp.prefix = bytepad(append(encodeString(n), encodeString(s)...), rate)
+	sp.Write(sp.prefix)
+	return sp
+}
+
+func NewCShake128(n []byte, s []byte) *Sponge { return newCShake(168, 32, n, s) }
+func NewCShake256(n []byte, s []byte) *Sponge { return newCShake(136, 64, n, s) }
+
+// implements hash.Hash
+type KMAC struct {
+	s Sponge
+}
+
+func newKmac(rate int, key []byte, s []byte, size int) *KMAC {
+	k := &KMAC{s: *newCShake(rate, size, []byte("KMAC"), s)}
+	enc := encodeString(key)
+	padded := bytepad(enc, rate)
+	prefix := make([]byte, 0, len(k.s.prefix)+len(padded))
+	k.s.prefix = append(append(prefix, k.s.prefix...), padded...)
+	wipe(enc)
+	wipe(padded)
+	k.Reset()
+	return k
+}
+
+// size is the length of the output (in bytes), which affects the result
+func NewKMAC128(key []byte, s []byte, size int) *KMAC { return newKmac(168, key, s, size) }
+func NewKMAC256(key []byte, s []byte, size int) *KMAC { return newKmac(136, key, s, size) }
+
+func (k *KMAC) Write(src []byte) (int, error) { return k.s.Write(src) }
+
+// appends the MAC of the data written so far, without changing the state
+func (k *KMAC) Sum(in []byte) []byte {
+	dup := k.s
+	dup.Write(rightEncode(uint64(dup.outputLen) * 8))
+	res := make([]byte, dup.outputLen)
+	dup.Read(res)
+	dup.wipeState()
+	return append(in, res...)
+}
+
+func (k *KMAC) Reset() { k.s.Reset() }
+
+// wipes the key and the state, the KMAC can not be used afterwards
+func (k *KMAC) Destroy() { k.s.Destroy() }
+
+func (k *KMAC) Size() int { return k.s.outputLen }
+
+func (k *KMAC) BlockSize() int { return k.s.rate }
+
+func Kmac256(key []byte, data []byte, s []byte, size int) []byte {
+	k := NewKMAC256(key, s, size)
+	defer k.Destroy()
+	k.Write(data)
+	return k.Sum(nil)
+}
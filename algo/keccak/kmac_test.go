@@ -0,0 +1,125 @@
+package keccak
+
+import (
+	"bytes"
+	"encoding/hex"
+	"hash"
+	"testing"
+)
+
+var _ hash.Hash = (*KMAC)(nil)
+
+func sequence(n int, start byte) []byte {
+	b := make([]byte, n)
+	for i := range b {
+		b[i] = start + byte(i)
+	}
+	return b
+}
+
+func TestEncoding(t *testing.T) {
+	if !bytes.Equal(leftEncode(0), []byte{1, 0}) || !bytes.Equal(leftEncode(256), []byte{2, 1, 0}) {
+		t.Fatal("leftEncode failed")
+	}
+	if !bytes.Equal(rightEncode(0), []byte{0, 1}) || !bytes.Equal(rightEncode(256), []byte{1, 0, 2}) {
+		t.Fatal("rightEncode failed")
+	}
+	if len(bytepad([]byte("zxcv"), 168)) != 168 || len(bytepad(make([]byte, 167), 168)) != 168*2 {
+		t.Fatal("bytepad failed")
+	}
+}
+
+// NIST SP 800-185 samples
+func TestCShakeVectors(t *testing.T) {
+	custom := []byte("Email Signature")
+	vectors := []struct {
+		newHash func([]byte, []byte) *Sponge
+		data    []byte
+		exp     string
+	}{
+		{NewCShake128, sequence(4, 0), "c1c36925b6409a04f1b504fcbca9d82b4017277cb5ed2b2065fc1d3814d5aaf5"},
+		{NewCShake128, sequence(200, 0), "c5221d50e4f822d96a2e8881a961420f294b7b24fe3d2094baed2c6524cc166b"},
+		{NewCShake256, sequence(4, 0), "d008828e2b80ac9d2218ffee1d070c48b8e4c87bff32c9699d5b6896eee0edd164020e2be0560858d9c00c037e34a96937c561a74c412bb4c746469527281c8c"},
+		{NewCShake256, sequence(200, 0), "07dc27b11e51fbac75bc7b3c1d983e8b4b85fb1defaf218912ac86430273091727f42b17ed1df63e8ec118f04b23633c1dfb1574c8fb55cb45da8e25afb092bb"},
+	}
+	for i, v := range vectors {
+		s := v.newHash(nil, custom)
+		s.Write(v.data)
+		if res := s.Sum(nil); hex.EncodeToString(res) != v.exp {
+			t.Fatalf("failed test number %d, result: \n[%x]", i, res)
+		}
+		s.Reset()
+		s.Write(v.data)
+		if res := s.Sum(nil); hex.EncodeToString(res) != v.exp {
+			t.Fatalf("reset failed, test number %d", i)
+		}
+	}
+
+	// without N and S cSHAKE is equivalent to SHAKE
+	if !bytes.Equal(NewCShake128(nil, nil).Sum(nil), ShakeSum128(nil, 32)) {
+		t.Fatal("empty cSHAKE128 != SHAKE128")
+	}
+}
+
+func TestKmacVectors(t *testing.T) {
+	key := sequence(32, 0x40)
+	custom := []byte("My Tagged Application")
+	vectors := []struct {
+		newMac func([]byte, []byte, int) *KMAC
+		data   []byte
+		s      []byte
+		size   int
+		exp    string
+	}{
+		{NewKMAC128, sequence(4, 0), nil, 32, "e5780b0d3ea6f7d3a429c5706aa43a00fadbd7d49628839e3187243f456ee14e"},
+		{NewKMAC128, sequence(4, 0), custom, 32, "3b1fba963cd8b0b59e8c1a6d71888b7143651af8ba0a7070c0979e2811324aa5"},
+		{NewKMAC128, sequence(200, 0), custom, 32, "1f5b4e6cca02209e0dcb5ca635b89a15e271ecc760071dfd805faa38f9729230"},
+		{NewKMAC256, sequence(4, 0), custom, 64, "20c570c31346f703c9ac36c61c03cb64c3970d0cfc787e9b79599d273a68d2f7f69d4cc3de9d104a351689f27cf6f5951f0103f33f4f24871024d9c27773a8dd"},
+		{NewKMAC256, sequence(200, 0), nil, 64, "75358cf39e41494e949707927cee0af20a3ff553904c86b08f21cc414bcfd691589d27cf5e15369cbbff8b9a4c2eb17800855d0235ff635da82533ec6b759b69"},
+		{NewKMAC256, sequence(200, 0), custom, 64, "b58618f71f92e1d56c1b8c55ddd7cd188b97b4ca4d99831eb2699a837da2e4d970fbacfde50033aea585f1a2708510c32d07880801bd182898fe476876fc8965"},
+	}
+	for i, v := range vectors {
+		m := v.newMac(key, v.s, v.size)
+		m.Write(v.data)
+		res := m.Sum(nil)
+		if hex.EncodeToString(res) != v.exp {
+			t.Fatalf("failed test number %d, result: \n[%x]", i, res)
+		}
+		if !bytes.Equal(m.Sum(nil), res) {
+			t.Fatalf("Sum changed the state, test number %d", i)
+		}
+		m.Reset()
+		m.Write(v.data)
+		if !bytes.Equal(m.Sum(nil), res) {
+			t.Fatalf("reset failed, test number %d", i)
+		}
+	}
+
+	res := Kmac256(key, sequence(200, 0), custom, 64)
+	if hex.EncodeToString(res) != vectors[5].exp {
+		t.Fatalf("Kmac256 failed, result: \n[%x]", res)
+	}
+	if bytes.Equal(Kmac256(key, nil, custom, 64)[:32], Kmac256(key, nil, custom, 32)) {
+		t.Fatal("output size does not affect the result")
+	}
+}
+
+func TestKmacDestroy(t *testing.T) {
+	key := sequence(32, 0x40)
+	m := NewKMAC256(key, nil, 64)
+	m.Write(sequence(200, 0))
+	m.Sum(nil)
+	prefix := m.s.prefix
+	m.Destroy()
+	if m.s.a != [25]uint64{} || m.s.storage != [maxRate]byte{} || m.s.prefix != nil {
+		t.Fatal("state is not wiped")
+	}
+	if bytes.Contains(prefix[:cap(prefix)], key) {
+		t.Fatal("key is not wiped")
+	}
+	for _, b := range prefix {
+		if b != 0 {
+			t.Fatal("prefix is not wiped")
+		}
+	}
+}
@@ -0,0 +1,161 @@
+package keccak
+
+import "errors"
+
+// Standard sponge functions (FIPS 202) built on the same permutation as Keccak512:
+// SHA3-224/256/384/512, SHAKE128/256 and legacy Keccak-256 (as used before standardization).
+// They differ only in the rate and the domain separation byte.
+
+const (
+	dsbyteLegacy = byte(0x01)
+	dsbyteSha3   = byte(0x06)
+	dsbyteShake  = byte(0x1f)
+	maxRate      = 168
+)
+
+type Sponge struct {
+	a         [25]uint64
+	rate      int
+	dsbyte    byte
+	outputLen int
+	storage   [maxRate]byte
+	n         int // number of buffered bytes while absorbing, or offset while squeezing
+	squeezing bool
+	prefix    []byte // absorbed after each reset (see cSHAKE)
+}
+
+func newSponge(rate int, dsbyte byte, outputLen int) *Sponge {
+	return &Sponge{rate: rate, dsbyte: dsbyte, outputLen: outputLen}
+}
+
+func New224() *Sponge             { return newSponge(144, dsbyteSha3, 28) }
+func New256() *Sponge             { return newSponge(136, dsbyteSha3, 32) }
+func New384() *Sponge             { return newSponge(104, dsbyteSha3, 48) }
+func New512() *Sponge             { return newSponge(72, dsbyteSha3, 64) }
+func NewShake128() *Sponge        { return newSponge(168, dsbyteShake, 32) }
+func NewShake256() *Sponge        { return newSponge(136, dsbyteShake, 64) }
+func NewLegacyKeccak256() *Sponge { return newSponge(136, dsbyteLegacy, 32) }
+
+// xor the block into the state (little endian, independent of the platform)
+func (s *Sponge) xorIn(block []byte) {
+	for i := 0; i < len(block)/8; i++ {
+		b := block[i*8:]
+		s.a[i] ^= uint64(b[0]) | uint64(b[1])<<8 | uint64(b[2])<<16 | uint64(b[3])<<24 |
+			uint64(b[4])<<32 | uint64(b[5])<<40 | uint64(b[6])<<48 | uint64(b[7])<<56
+	}
+}
+
+func (s *Sponge) copyOut() {
+	for i := 0; i < s.rate/8; i++ {
+		w := s.a[i]
+		for j := 0; j < 8; j++ {
+			s.storage[i*8+j] = byte(w >> (8 * uint(j)))
+		}
+	}
+}
+
+func (s *Sponge) Write(src []byte) (int, error) {
+	if s.squeezing {
+		return 0, errors.New("keccak: write after read")
+	}
+	n := len(src)
+	for len(src) > 0 {
+		c := copy(s.storage[s.n:s.rate], src)
+		s.n += c
+		src = src[c:]
+		if s.n == s.rate {
+			s.xorIn(s.storage[:s.rate])
+			permute(&s.a)
+			s.n = 0
+		}
+	}
+	return n, nil
+}
+
+// appends the domain separation bits, applies the padding rule, and permutes the state
+func (s *Sponge) finalize() {
+	s.storage[s.n] = s.dsbyte
+	for i := s.n + 1; i < s.rate; i++ {
+		s.storage[i] = 0
+	}
+	s.storage[s.rate-1] ^= 0x80
+	s.xorIn(s.storage[:s.rate])
+	permute(&s.a)
+	s.copyOut()
+	s.n = 0
+	s.squeezing = true
+}
+
+// reads arbitrary amount of output (the sponge can not absorb any more data after that)
+func (s *Sponge) Read(dst []byte) (int, error) {
+	if !s.squeezing {
+		s.finalize()
+	}
+	n := len(dst)
+	for len(dst) > 0 {
+		if s.n == s.rate {
+			permute(&s.a)
+			s.copyOut()
+			s.n = 0
+		}
+		c := copy(dst, s.storage[s.n:s.rate])
+		s.n += c
+		dst = dst[c:]
+	}
+	return n, nil
+}
+
+// appends the digest of the data written so far, without changing the state
+func (s *Sponge) Sum(in []byte) []byte {
+	dup := *s
+	hash := make([]byte, s.outputLen)
+	dup.Read(hash)
+	return append(in, hash...)
+}
+
+func (s *Sponge) Reset() {
+	s.a = [25]uint64{}
+	s.n = 0
+	s.squeezing = false
+	if len(s.prefix) > 0 {
+		s.Write(s.prefix)
+	}
+}
+
+func (s *Sponge) wipeState() {
+	s.a = [25]uint64{}
+	s.storage = [maxRate]byte{}
+	s.n = 0
+	s.squeezing = false
+}
+
+// wipes the state and the prefix (which may contain the key, see KMAC)
+func (s *Sponge) Destroy() {
+	s.wipeState()
+	wipe(s.prefix)
+	s.prefix = nil
+}
+
+func (s *Sponge) Size() int { return s.outputLen }
+
+func (s *Sponge) BlockSize() int { return s.rate }
+
+func sum(s *Sponge, data []byte) []byte {
+	s.Write(data)
+	return s.Sum(nil)
+}
+
+func shake(s *Sponge, data []byte, sz int) []byte {
+	res := make([]byte, sz)
+	s.Write(data)
+	s.Read(res)
+	return res
+}
+
+func Sum224(data []byte) []byte              { return sum(New224(), data) }
+func Sum256(data []byte) []byte              { return sum(New256(), data) }
+func Sum384(data []byte) []byte              { return sum(New384(), data) }
+func Sum512(data []byte) []byte              { return sum(New512(), data) }
+func LegacySum256(data []byte) []byte        { return sum(NewLegacyKeccak256(), data) }
+func ShakeSum128(data []byte, sz int) []byte { return shake(NewShake128(), data, sz) }
+func ShakeSum256(data []byte, sz int) []byte { return shake(NewShake256(), data, sz) }
@@ -0,0 +1,108 @@
+package keccak
+
+import (
+	"bytes"
+	"encoding/hex"
+	mrand "math/rand"
+	"testing"
+	"time"
+)
+
+var msgAbc = "abc"
+var msg448 = "abcdbcdecdefdefgefghfghighijhijkijkljklmklmnlmnomnopnopq"
+
+// NIST examples (FIPS 202), and 1600 bits of 0xa3
+var sha3Vectors = []struct {
+	name string
+	f    func([]byte) []byte
+	msg  string
+	exp  string
+}{
+	{"SHA3-224", Sum224, "", "6b4e03423667dbb73b6e15454f0eb1abd4597f9a1b078e3f5b5a6bc7"},
+	{"SHA3-224", Sum224, msgAbc, "e642824c3f8cf24ad09234ee7d3c766fc9a3a5168d0c94ad73b46fdf"},
+	{"SHA3-256", Sum256, "", "a7ffc6f8bf1ed76651c14756a061d662f580ff4de43b49fa82d80a4b80f8434a"},
+	{"SHA3-256", Sum256, msgAbc, "3a985da74fe225b2045c172d6bd390bd855f086e3e9d525b46bfe24511431532"},
+	{"SHA3-256", Sum256, msg448, "41c0dba2a9d6240849100376a8235e2c82e1b9998a999e21db32dd97496d3376"},
+	{"SHA3-256", Sum256, string(bytes.Repeat([]byte{0xa3}, 200)), "79f38adec5c20307a98ef76e8324afbfd46cfd81b22e3973c65fa1bd9de31787"},
+	{"SHA3-384", Sum384, "", "0c63a75b845e4f7d01107d852e4c2485c51a50aaaa94fc61995e71bbee983a2ac3713831264adb47fb6bd1e058d5f004"},
+	{"SHA3-384", Sum384, msgAbc, "ec01498288516fc926459f58e2c6ad8df9b473cb0fc08c2596da7cf0e49be4b298d88cea927ac7f539f1edf228376d25"},
+	{"SHA3-512", Sum512, "", "a69f73cca23a9ac5c8b567dc185a756e97c982164fe25859e0d1dcc1475c80a615b2123af1f5f94c11e3e9402c3ac558f500199d95b6d3e301758586281dcd26"},
+	{"SHA3-512", Sum512, msgAbc, "b751850b1a57168a5693cd924b6b096e08f621827444f70d884f5d0240d2712e10e116e9192af3c91a7ec57647e3934057340b4cf408d5a56592f8274eec53f0"},
+	{"Keccak-256", LegacySum256, "", "c5d2460186f7233c927e7db2dcc703c0e500b653ca82273b7bfad8045d85a470"},
+	{"Keccak-256", LegacySum256, msgAbc, "4e03657aea45a94fc7d47ba826c8d667c0d1e6e33a64a036ec44f58fa12d6c45"},
+}
+
+func TestSha3Vectors(t *testing.T) {
+	for i, v := range sha3Vectors {
+		res := v.f([]byte(v.msg))
+		if hex.EncodeToString(res) != v.exp {
+			t.Fatalf("%s failed test number %d, result: \n[%x]", v.name, i, res)
+		}
+	}
+}
+
+func TestShakeVectors(t *testing.T) {
+	res := ShakeSum128(nil, 32)
+	if hex.EncodeToString(res) != "7f9c2ba4e88f827d616045507605853ed73b8093f6efbc88eb1a6eacfa66ef26" {
+		t.Fatalf("SHAKE128 failed, result: \n[%x]", res)
+	}
+	res = ShakeSum256(nil, 64)
+	if hex.EncodeToString(res) != "46b9dd2b0ba88d13233b3feb743eeb243fcd52ea62b81b82b50c27646ed5762fd75dc4ddd8c0f200cb05019d67b592f6fc821c49479ab48640292eacb3b7c4be" {
+		t.Fatalf("SHAKE256 failed, result: \n[%x]", res)
+	}
+
+	// the output must not depend on the size of the reads
+	long := ShakeSum128([]byte(msgAbc), 1000)
+	s := NewShake128()
+	s.Write([]byte(msgAbc))
+	buf := make([]byte, 0, 1000)
+	for len(buf) < 1000 {
+		b := make([]byte, mrand.Intn(300)+1)
+		if len(buf)+len(b) > 1000 {
+			b = b[:1000-len(buf)]
+		}
+		s.Read(b)
+		buf = append(buf, b...)
+	}
+	if !bytes.Equal(buf, long) {
+		t.Fatal("incremental read failed")
+	}
+}
+
+func TestSpongeIncremental(t *testing.T) {
+	seed := time.Now().Unix()
+	mrand.Seed(seed)
+
+	data := make([]byte, 2000)
+	mrand.Read(data)
+	for _, newHash := range []func() *Sponge{New224, New256, New384, New512, NewShake128, NewShake256, NewLegacyKeccak256} {
+		s := newHash()
+		s.Write(data)
+		expected := s.Sum(nil)
+		if len(expected) != s.Size() {
+			t.Fatalf("wrong size %d vs. %d", len(expected), s.Size())
+		}
+
+		s.Reset()
+		for d := data; len(d) > 0; {
+			n := mrand.Intn(s.BlockSize()*2) + 1
+			if n > len(d) {
+				n = len(d)
+			}
+			s.Write(d[:n])
+			d = d[n:]
+			if len(d) == 0 {
+				break
+			}
+			s.Sum(nil) // must not change the state
+		}
+		if res := s.Sum(nil); !bytes.Equal(res, expected) {
+			t.Fatalf("incremental write failed, seed %d", seed)
+		}
+
+		s.Read(make([]byte, 1))
+		if _, err := s.Write(data); err == nil {
+			t.Fatal("write after read succeeded")
+		}
+	}
+}
@@ -0,0 +1,138 @@
+package common
+
+import (
+	"bytes"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gluk256/crypto/algo/keccak"
+	"github.com/gluk256/crypto/crutils"
+	"github.com/gluk256/crypto/terminal"
+)
+
+// ASCII armor: base64-encoded data between BEGIN/END lines, convenient for emails and chats.
+// Optional header fields (e.g. version and mode) are separated from the data by an empty line.
+// The last data line is checksum: '=' followed by base64-encoded keccak digest of the data.
+//
+// -----BEGIN XCRY MESSAGE-----
+// Version: 2
+// Mode: main
+//
+// base64 data, wrapped at ArmorLineSize
+// =checksum
+// -----END XCRY MESSAGE-----
+
+const (
+	ArmorMessage      = "XCRY MESSAGE"
+	ArmorLineSize     = 64
+	armorBegin        = "-----BEGIN "
+	armorEnd          = "-----END "
+	armorDashes       = "-----"
+	armorChecksumSize = 3
+)
+
+func armorChecksum(data []byte) string {
+	return "=" + base64.StdEncoding.EncodeToString(keccak.Digest(data, armorChecksumSize))
+}
+
+// fields are expected in the form "Name: value"
+func Armor(kind string, data []byte, fields ...string) []byte {
+	var b bytes.Buffer
+	b.WriteString(armorBegin + kind + armorDashes + "\n")
+	for _, f := range fields {
+		b.WriteString(f + "\n")
+	}
+	if len(fields) > 0 {
+		b.WriteString("\n")
+	}
+	s := base64.StdEncoding.EncodeToString(data)
+	for len(s) > ArmorLineSize {
+		b.WriteString(s[:ArmorLineSize] + "\n")
+		s = s[ArmorLineSize:]
+	}
+	if len(s) > 0 {
+		b.WriteString(s + "\n")
+	}
+	b.WriteString(armorChecksum(data) + "\n")
+	b.WriteString(armorEnd + kind + armorDashes + "\n")
+	return b.Bytes()
+}
+
+// adds the header fields, if the header is present
+func ArmorCiphertext(data []byte) []byte {
+	if h, err := crutils.ParseHeader(data); err == nil {
+		return Armor(ArmorMessage, data, fmt.Sprintf("Version: %d", h.Version), "Mode: "+crutils.ModeName(h.Mode))
+	}
+	return Armor(ArmorMessage, data)
+}
+
+func IsArmored(data []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(data), []byte(armorBegin))
+}
+
+func isArmorEnd(line []byte) bool {
+	return bytes.HasPrefix(bytes.TrimSpace(line), []byte(armorEnd))
+}
+
+// don't forget to annihilate the result!
+func Dearmor(text []byte) (kind string, fields map[string]string, data []byte, err error) {
+	lines := strings.Split(strings.TrimSpace(string(text)), "\n")
+	first := strings.TrimSpace(lines[0])
+	if !strings.HasPrefix(first, armorBegin) || !strings.HasSuffix(first, armorDashes) || len(first) <= len(armorBegin)+len(armorDashes) {
+		return "", nil, nil, errors.New("armor begin line not found")
+	}
+	kind = first[len(armorBegin) : len(first)-len(armorDashes)]
+
+	var body, checksum string
+	fields = make(map[string]string)
+	for i, line := range lines[1:] {
+		line = strings.TrimSpace(line)
+		if line == armorEnd+kind+armorDashes {
+			if i+2 != len(lines) {
+				return kind, nil, nil, errors.New("unexpected data after armor end line")
+			}
+			if len(checksum) == 0 {
+				return kind, nil, nil, errors.New("armor checksum not found")
+			}
+			data, err = base64.StdEncoding.DecodeString(body)
+			if err != nil {
+				return kind, nil, nil, fmt.Errorf("wrong armored data: %s", err.Error())
+			}
+			if armorChecksum(data) != checksum {
+				crutils.AnnihilateData(data)
+				return kind, nil, nil, errors.New("armor checksum mismatch")
+			}
+			return kind, fields, data, nil
+		} else if len(checksum) > 0 {
+			return kind, nil, nil, errors.New("unexpected data after armor checksum")
+		} else if strings.HasPrefix(line, "=") {
+			checksum = line
+		} else if n := strings.Index(line, ":"); n > 0 { // colon is not in the base64 alphabet
+			fields[line[:n]] = strings.TrimSpace(line[n+1:])
+		} else {
+			body += line
+		}
+	}
+	return kind, nil, nil, errors.New("armor end line not found")
+}
+
+// reads the rest of the armored text from the terminal, if the first line starts the armor
+func CompleteArmoredInput(first []byte) []byte {
+	if !IsArmored(first) {
+		return first
+	}
+	res := append(first, '\n')
+	for {
+		line := terminal.PlainTextInput()
+		if line == nil {
+			return res
+		}
+		res = append(res, line...)
+		res = append(res, '\n')
+		if isArmorEnd(line) {
+			return res
+		}
+	}
+}
@@ -0,0 +1,122 @@
+package common
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+
+	"github.com/gluk256/crypto/crutils"
+)
+
+// Batch mode (flag 'b'): non-interactive operation for scripts and cron jobs.
+// The password is taken from the file descriptor, or from the file, or from the environment variable
+// (in this order of preference). Environment variable is the least secure option, since the environment
+// of the process might be visible to other users, and can not be annihilated.
+// Keyfiles (flag 'k') are listed in the environment variable, separated by the path list separator.
+// All confirmation prompts are answered negatively, and the result is reported via exit code.
+// The same password is used for all the prompts of one run (e.g. for the private key as well), so the
+// password from the file descriptor is read only once, and kept in the locked memory until the process exits.
+
+const (
+	EnvPassword     = "XCRY_PASSWORD"
+	EnvPasswordFd   = "XCRY_PASSWORD_FD"
+	EnvPasswordFile = "XCRY_PASSWORD_FILE"
+	EnvKeyfiles     = "XCRY_KEYFILES"
+	EnvKeyName      = "XCRY_KEY_NAME"
+)
+
+var Batch bool // no terminal input allowed
+
+var (
+	fdPassword    *crutils.SecureBuffer // the descriptor can be read only once
+	fdPasswordEnv string
+)
+
+func PrintBatchHelp() {
+	fmt.Printf("batch mode: the password is read from the file descriptor [%s], or from the file [%s], ", EnvPasswordFd, EnvPasswordFile)
+	fmt.Printf("or from the environment variable [%s]; keyfiles are listed in [%s]; key name is [%s]\n", EnvPassword, EnvKeyfiles, EnvKeyName)
+}
+
+// the trailing newline is not considered to be a part of the password
+func trimNewline(b []byte) []byte {
+	for len(b) > 0 && (b[len(b)-1] == '\n' || b[len(b)-1] == '\r') {
+		b[len(b)-1] = 0
+		b = b[:len(b)-1]
+	}
+	return b
+}
+
+// don't forget to annihilate the result!
+func readPasswordFd(s string) ([]byte, error) {
+	fd, err := strconv.ParseUint(s, 10, 32)
+	if err != nil {
+		return nil, fmt.Errorf("wrong file descriptor [%s]", s)
+	}
+	f := os.NewFile(uintptr(fd), "password")
+	if f == nil {
+		return nil, fmt.Errorf("wrong file descriptor [%s]", s)
+	}
+	defer f.Close()
+	res, err := ioutil.ReadAll(f)
+	return trimNewline(res), err
+}
+
+// don't forget to annihilate the result!
+func getBatchPassword() ([]byte, error) {
+	if s := os.Getenv(EnvPasswordFd); len(s) > 0 {
+		if fdPassword == nil || fdPasswordEnv != s {
+			res, err := readPasswordFd(s)
+			if err != nil {
+				crutils.AnnihilateData(res)
+				return nil, err
+			}
+			fdPassword.Destroy()
+			fdPassword = crutils.NewSecureBufferFrom(res)
+			fdPasswordEnv = s
+		}
+		res := make([]byte, fdPassword.Len())
+		copy(res, fdPassword.Bytes())
+		return res, nil
+	}
+	if name := os.Getenv(EnvPasswordFile); len(name) > 0 {
+		res, err := ioutil.ReadFile(name)
+		return trimNewline(res), err
+	}
+	if s, ok := os.LookupEnv(EnvPassword); ok {
+		return []byte(s), nil
+	}
+	return nil, errors.New("password is not provided in batch mode")
+}
+
+// don't forget to annihilate the result!
+func getBatchKeyfiles() (res [][]byte, err error) {
+	for _, name := range filepath.SplitList(os.Getenv(EnvKeyfiles)) {
+		var h []byte
+		if name == "x" {
+			err = errors.New("hex secret is not allowed in batch mode")
+		} else if len(name) > 0 {
+			h, err = getKeyfileHash(name)
+		}
+		if err != nil {
+			annihilateKeyfiles(res)
+			return nil, fmt.Errorf("failed to load keyfile [%s]: %s", name, err.Error())
+		}
+		if h != nil {
+			res = append(res, h)
+		}
+	}
+	if len(res) == 0 {
+		return nil, errors.New("no keyfiles")
+	}
+	return res, nil
+}
+
+// should be called at the very end, since deferred functions are not executed
+func Exit(err error) {
+	if err != nil {
+		os.Exit(1)
+	}
+}
@@ -1,7 +1,15 @@
 package common
 
 import (
+	"bytes"
+	"encoding/hex"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
 	"testing"
+
+	"github.com/gluk256/crypto/crutils"
 )
 
 func TestEncoding(t *testing.T) {
@@ -14,3 +22,249 @@ func TestEncoding(t *testing.T) {
 		t.Fatal("false negative")
 	}
 }
+
+func TestGetHeader(t *testing.T) {
+	if GetHeader("ed", crutils.ModeMain) != nil {
+		t.Fatal("header is not requested")
+	}
+	h := GetHeader("ev", crutils.ModeQuick)
+	if h == nil || h.Mode != crutils.ModeQuick || h.Kdf != crutils.KdfKeccak {
+		t.Fatal("wrong header")
+	}
+	h = GetHeader("ea", crutils.ModeMain)
+	if h == nil || h.Kdf != crutils.KdfArgon2id {
+		t.Fatal("wrong kdf")
+	}
+	h = GetHeader("ey", crutils.ModeMain)
+	if h == nil || h.Kdf != crutils.KdfScrypt {
+		t.Fatal("wrong kdf")
+	}
+	h = GetHeader("ez", crutils.ModeMain)
+	if h == nil || !h.IsCompressed() || h.Kdf != crutils.KdfKeccak {
+		t.Fatal("compression is not requested")
+	}
+	if GetHeader("ez", crutils.ModeQuick) != nil {
+		t.Fatal("compression requested in quick mode")
+	}
+}
+
+func TestCombineKeyfiles(t *testing.T) {
+	password := []byte("7eab42de4c3ceb9235fc91acffe746b29c29a8c366b7c60e4e67c466f36a4304")
+	k1 := bytes.Repeat([]byte{1}, 256)
+	k2 := bytes.Repeat([]byte{2}, 256)
+	k3 := bytes.Repeat([]byte{3}, 256)
+
+	a := combineKeyfiles(password, [][]byte{k1, k2, k3})
+	b := combineKeyfiles(password, [][]byte{k3, k1, k2})
+	if len(a) != 256 || !bytes.Equal(a, b) {
+		t.Fatal("result depends on the order of keyfiles")
+	}
+	if bytes.Equal(a, combineKeyfiles(password, [][]byte{k1, k2})) {
+		t.Fatal("missing keyfile ignored")
+	}
+	if bytes.Equal(a, combineKeyfiles(password[1:], [][]byte{k1, k2, k3})) {
+		t.Fatal("password ignored")
+	}
+}
+
+func TestArmor(t *testing.T) {
+	data := make([]byte, 1000)
+	for i := range data {
+		data[i] = byte(i * 7)
+	}
+	for _, sz := range []int{0, 1, 47, 48, 49, len(data)} {
+		armored := Armor(ArmorMessage, data[:sz], "Version: 2", "Mode: main")
+		if !IsArmored(armored) || !IsAscii(bytes.Replace(armored, []byte("\n"), []byte(" "), -1)) {
+			t.Fatalf("wrong armor, size %d", sz)
+		}
+		kind, fields, res, err := Dearmor(armored)
+		if err != nil {
+			t.Fatalf("size %d: %s", sz, err)
+		}
+		if kind != ArmorMessage || fields["Version"] != "2" || fields["Mode"] != "main" {
+			t.Fatalf("wrong header fields, size %d: %s %v", sz, kind, fields)
+		}
+		if !bytes.Equal(res, data[:sz]) {
+			t.Fatalf("dearmored data differs, size %d", sz)
+		}
+	}
+
+	armored := Armor("TEST", data)
+	_, _, res, err := Dearmor(bytes.Replace(armored, []byte("\n"), []byte("\r\n"), -1))
+	if err != nil || !bytes.Equal(res, data) {
+		t.Fatal("failed to dearmor text with CRLF line endings")
+	}
+
+	tampered := make([]byte, len(armored))
+	copy(tampered, armored)
+	tampered[40] ^= 1
+	if _, _, _, err = Dearmor(tampered); err == nil {
+		t.Fatal("tampered data not detected")
+	}
+	if _, _, _, err = Dearmor(armored[:len(armored)-10]); err == nil {
+		t.Fatal("truncated armor not detected")
+	}
+	wrongEnd := bytes.Replace(armored, []byte("END TEST"), []byte("END XCRY"), 1)
+	if _, _, _, err = Dearmor(wrongEnd); err == nil {
+		t.Fatal("wrong end line not detected")
+	}
+	if IsArmored([]byte("0123abcd")) {
+		t.Fatal("hex data detected as armored")
+	}
+}
+
+func TestBatchPassword(t *testing.T) {
+	for _, env := range []string{EnvPassword, EnvPasswordFd, EnvPasswordFile} {
+		os.Unsetenv(env)
+	}
+	if _, err := getBatchPassword(); err == nil {
+		t.Fatal("password is not provided, but no error")
+	}
+
+	os.Setenv(EnvPassword, "from environment")
+	defer os.Unsetenv(EnvPassword)
+	if p, err := getBatchPassword(); err != nil || string(p) != "from environment" {
+		t.Fatalf("wrong password from environment: [%s], %v", p, err)
+	}
+
+	f, err := ioutil.TempFile("", "xcry-batch")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.Remove(f.Name())
+	f.WriteString("from file\n")
+	f.Close()
+	os.Setenv(EnvPasswordFile, f.Name())
+	defer os.Unsetenv(EnvPasswordFile)
+	if p, err := getBatchPassword(); err != nil || string(p) != "from file" {
+		t.Fatalf("wrong password from file: [%s], %v", p, err)
+	}
+
+	r, w, err := os.Pipe()
+	if err != nil {
+		t.Fatal(err)
+	}
+	w.WriteString("from descriptor\r\n")
+	w.Close()
+	os.Setenv(EnvPasswordFd, strconv.Itoa(int(r.Fd())))
+	defer os.Unsetenv(EnvPasswordFd)
+	if p, err := getBatchPassword(); err != nil || string(p) != "from descriptor" {
+		t.Fatalf("wrong password from descriptor: [%s], %v", p, err)
+	}
+	// e.g. the private key password is requested after the data password
+	if p, err := getBatchPassword(); err != nil || string(p) != "from descriptor" {
+		t.Fatalf("the second password from descriptor: [%s], %v", p, err)
+	}
+
+	os.Setenv(EnvPasswordFd, "wrong")
+	if _, err = getBatchPassword(); err == nil {
+		t.Fatal("wrong descriptor accepted")
+	}
+}
+
+func TestWriteFileAtomic(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xcry-save")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "data")
+
+	if err = WriteFileAtomic(name, []byte("first")); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0600 {
+		t.Fatalf("wrong permissions %o", info.Mode().Perm())
+	}
+
+	Backup = true
+	defer func() { Backup = false }()
+	link := filepath.Join(dir, "link")
+	if err = os.Symlink(name, link); err != nil {
+		t.Fatal(err)
+	}
+	if err = WriteFileAtomic(link, []byte("second")); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(name); string(b) != "second" {
+		t.Fatalf("file is not overwritten: [%s]", b)
+	}
+	if b, _ := ioutil.ReadFile(name + ".~1~"); string(b) != "first" {
+		t.Fatalf("wrong backup: [%s]", b)
+	}
+	if fi, err := os.Lstat(link); err != nil || fi.Mode()&os.ModeSymlink == 0 {
+		t.Fatal("symlink is replaced")
+	}
+
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 3 {
+		t.Fatalf("temporary file remains, %d files", len(files))
+	}
+
+	// the temporary file must be created in the directory of the target, not of the link
+	Backup = false
+	sub := filepath.Join(dir, "sub")
+	if err = os.Mkdir(sub, 0700); err != nil {
+		t.Fatal(err)
+	}
+	link = filepath.Join(sub, "link")
+	if err = os.Symlink(name, link); err != nil {
+		t.Fatal(err)
+	}
+	f, err := CreateTemp(link)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if filepath.Dir(f.Name()) != dir {
+		t.Fatalf("temporary file in wrong dir: %s", f.Name())
+	}
+	f.Write([]byte("third"))
+	if err = CommitTemp(f, link); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(name); string(b) != "third" {
+		t.Fatalf("file is not overwritten: [%s]", b)
+	}
+	if files, _ = ioutil.ReadDir(sub); len(files) != 1 {
+		t.Fatalf("wrong number of files in the link dir: %d", len(files))
+	}
+}
+
+func TestLoadPublicKey(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xcry-keys")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	name := filepath.Join(dir, "key")
+
+	pub, priv, err := crutils.GenerateSigningKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	crutils.AnnihilateData(priv)
+	if err = ioutil.WriteFile(name, []byte(hex.EncodeToString(pub)+"\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	res, err := LoadPublicKey(SigningKey, name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(res, pub) {
+		t.Fatal("wrong key")
+	}
+
+	if err = ioutil.WriteFile(name, []byte(hex.EncodeToString(pub[:20])), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = LoadPublicKey(EncryptionKey, name); err == nil {
+		t.Fatal("short key accepted")
+	}
+	if EncryptionKey.PublicExt == SigningKey.PublicExt || EncryptionKey.PrivateExt == SigningKey.PrivateExt {
+		t.Fatal("same extensions")
+	}
+}
@@ -14,33 +14,79 @@ import (
 	"github.com/gluk256/crypto/terminal"
 )
 
-func GetPasswordRaw(flags string) (res []byte, err error) {
-	if strings.Contains(flags, "r") {
-		res, err = crutils.GenerateRandomPassword(20)
-		fmt.Println(string(res))
+// don't forget to annihilate the result!
+func GetPasswordRaw(flags string) ([]byte, error) {
+	pass, err := getPasswordLocked(flags)
+	res := make([]byte, pass.Len())
+	copy(res, pass.Bytes())
+	pass.Destroy()
+	return res, err
+}
+
+// the password entered by the user never leaves the locked memory; don't forget to destroy the result!
+func getPasswordLocked(flags string) (res *crutils.SecureBuffer, err error) {
+	if Batch {
+		var b []byte
+		b, err = getBatchPassword()
+		res = crutils.NewSecureBufferFrom(b)
+	} else if strings.Contains(flags, "r") {
+		var b []byte
+		b, err = crutils.GenerateRandomPassword(20)
+		fmt.Println(string(b))
+		res = crutils.NewSecureBufferFrom(b)
 	} else if strings.Contains(flags, "x") {
 		fmt.Println()
-		res = terminal.SecureInput(true)
+		res = terminal.SecureInputLocked(true)
 	} else if strings.Contains(flags, "s") {
 		fmt.Println()
-		res = terminal.SecureInput(false)
+		res = terminal.SecureInputLocked(false)
 	} else {
 		fmt.Print("please enter the password: ")
-		res = terminal.PasswordModeInput()
+		res = terminal.PasswordModeInputLocked()
 	}
-	if len(res) < 2 {
+	if err == nil && res.Len() < 2 {
 		err = errors.New("password is too short")
 	}
-	//res = keccak.Digest(res, 256) // moved to GetPassword; todo: delete
 	return res, err
 }
 
+// the result is further processed by the memory-hard KDF, if it is specified in the header (see GetHeader).
+// if flags contain 'k', the password is combined with keyfiles (see keyfiles.go).
 func GetPassword(flags string) (res []byte, err error) {
-	res, err = GetPasswordRaw(flags)
-	res = keccak.Digest(res, 256) // the keys for all crypto apps must always be 256 bytes
+	pass, err := getPasswordLocked(flags)
+	defer pass.Destroy()
+	if err == nil && strings.Contains(flags, "k") {
+		var keyfiles [][]byte
+		keyfiles, err = loadKeyfiles()
+		if err == nil {
+			res = combineKeyfiles(pass.Bytes(), keyfiles)
+			annihilateKeyfiles(keyfiles)
+			return res, nil
+		}
+	}
+	res = keccak.Digest(pass.Bytes(), 256) // the keys for all crypto apps must always be 256 bytes
 	return res, err
 }
 
+// returns nil, unless the header is requested ('v'), either explicitly or implicitly by the KDF flags ('a', 'y'),
+// or by compression ('z', main mode only)
+func GetHeader(flags string, mode byte) *crutils.Header {
+	compressed := strings.Contains(flags, "z") && mode == crutils.ModeMain
+	if !strings.ContainsAny(flags, "vay") && !compressed {
+		return nil
+	}
+	h := crutils.NewHeader(mode)
+	if strings.Contains(flags, "a") {
+		h.SetKdf(crutils.KdfArgon2id)
+	} else if strings.Contains(flags, "y") {
+		h.SetKdf(crutils.KdfScrypt)
+	}
+	if compressed {
+		h.Flags |= crutils.FlagCompressed
+	}
+	return h
+}
+
 func IsAscii(data []byte) bool {
 	for _, c := range data {
 		if c < 32 { // ignore c > 127 (could be some other alphabet encoding)
@@ -60,6 +106,10 @@ func IsHexData(data []byte) bool {
 }
 
 func GetFileName() string {
+	if Batch {
+		fmt.Println("Error: file name is required in batch mode")
+		return ""
+	}
 	for i := 0; i < 3; i++ {
 		fmt.Print("please enter file name: ")
 		f := terminal.PlainTextInput()
@@ -75,6 +125,7 @@ func GetFileName() string {
 	return ""
 }
 
+// the file is saved atomically (see save.go), the existing file is not overwritten without confirmation
 func SaveData(filename string, data []byte) error {
 	if len(data) == 0 {
 		fmt.Println("Error: content is absent, file is not saved.")
@@ -88,8 +139,16 @@ func SaveData(filename string, data []byte) error {
 				break
 			}
 		}
+		if !ConfirmOverwrite(filename) {
+			continue
+		}
 
-		err := ioutil.WriteFile(filename, data, 0666)
+		var err error
+		if filename == StdStream {
+			err = writeStdout(data)
+		} else {
+			err = WriteFileAtomic(filename, data)
+		}
 		if err == nil {
 			return nil
 		} else {
@@ -176,6 +235,10 @@ func GetHexData(legend string) (res []byte) {
 }
 
 func Confirm(question string) bool {
+	if Batch {
+		fmt.Printf("%s [n] (batch mode)\n", question)
+		return false
+	}
 	fmt.Printf("%s [y/n] ", question)
 	s := terminal.PlainTextInput()
 	if s == nil {
@@ -0,0 +1,95 @@
+package common
+
+import (
+	"bytes"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"sort"
+
+	"github.com/gluk256/crypto/algo/keccak"
+	"github.com/gluk256/crypto/crutils"
+	"github.com/gluk256/crypto/terminal"
+)
+
+// Two-factor keys: the password is combined with one or more keyfiles (flag 'k'),
+// so that the password alone is not enough for decryption.
+// Keyfile is either the certificate (see LoadCertificate), or arbitrary file, or hex-encoded secret.
+// The order of the keyfiles does not matter.
+
+const keyfilesCustomization = "xcry keyfiles"
+
+func getKeyfileHash(name string) ([]byte, error) {
+	if name == "c" {
+		return LoadCertificate(false)
+	}
+
+	var data []byte
+	var err error
+	if name == "x" {
+		fmt.Print("please enter hex-encoded secret: ")
+		raw := terminal.PasswordModeInputLocked()
+		data = make([]byte, raw.Len()/2)
+		_, err = hex.Decode(data, raw.Bytes())
+		raw.Destroy()
+	} else {
+		data, err = ioutil.ReadFile(name)
+	}
+	if err != nil {
+		crutils.AnnihilateData(data)
+		return nil, err
+	}
+	if len(data) == 0 {
+		return nil, errors.New("empty keyfile")
+	}
+	h := keccak.Digest(data, 256)
+	crutils.AnnihilateData(data)
+	return h, nil
+}
+
+// don't forget to annihilate the result!
+func loadKeyfiles() (res [][]byte, err error) {
+	if Batch {
+		return getBatchKeyfiles()
+	}
+	for {
+		fmt.Print("please enter keyfile name ('c' for certificate, 'x' for hex secret, empty to finish): ")
+		name := string(terminal.PlainTextInput())
+		if len(name) == 0 {
+			break
+		}
+		h, err := getKeyfileHash(name)
+		if err != nil {
+			fmt.Printf("Failed to load keyfile: %s\n", err.Error())
+			if name == "c" || !Confirm("Do you want to retry?") {
+				annihilateKeyfiles(res)
+				return nil, err
+			}
+			continue
+		}
+		res = append(res, h)
+	}
+	if len(res) == 0 {
+		return nil, errors.New("no keyfiles")
+	}
+	return res, nil
+}
+
+func annihilateKeyfiles(keyfiles [][]byte) {
+	for _, k := range keyfiles {
+		crutils.AnnihilateData(k)
+	}
+}
+
+// the result does not depend on the order of keyfiles
+func combineKeyfiles(password []byte, keyfiles [][]byte) []byte {
+	sort.Slice(keyfiles, func(i, j int) bool { return bytes.Compare(keyfiles[i], keyfiles[j]) < 0 })
+	all := make([]byte, 0, len(keyfiles)*256)
+	for _, k := range keyfiles {
+		all = append(all, k...)
+	}
+	res := keccak.Kmac256(password, all, []byte(keyfilesCustomization), 256)
+	crutils.AnnihilateData(all)
+	return res
+}
@@ -0,0 +1,147 @@
+package common
+
+import (
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/gluk256/crypto/crutils"
+	"github.com/gluk256/crypto/terminal"
+)
+
+// The key pairs are stored in the crypto dir (see GetCryptoDir).
+// Public keys are stored in hex format, private keys are encrypted with password (argon2id).
+// Encryption (X25519) and signing (Ed25519) keys have different extensions, so they never get mixed up.
+
+type KeyType struct {
+	PublicExt   string
+	PrivateExt  string
+	PublicSize  int
+	PrivateSize int
+}
+
+var (
+	EncryptionKey = KeyType{".pub", ".sec", crutils.PublicKeySize, crutils.PrivateKeySize}
+	SigningKey    = KeyType{".spub", ".ssec", crutils.SignPublicKeySize, crutils.SignPrivateKeySize}
+)
+
+// the name is either a path to existing file, or the name of the key in the crypto dir
+func getKeyFileName(name string, ext string) string {
+	if _, err := os.Stat(name); err == nil {
+		return name
+	}
+	return GetFullFileName(name + ext)
+}
+
+func writeNewFile(filename string, data []byte, perm os.FileMode) error {
+	f, err := os.OpenFile(filename, os.O_WRONLY|os.O_CREATE|os.O_EXCL, perm)
+	if err != nil {
+		return err
+	}
+	_, err = f.Write(data)
+	if err == nil {
+		err = f.Sync()
+	}
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	return err
+}
+
+func SaveKeyPair(t KeyType, name string, pub []byte, priv []byte, flags string) error {
+	if len(name) == 0 || strings.ContainsRune(name, '/') {
+		return fmt.Errorf("wrong key name [%s]", name)
+	}
+	dir, exist := GetCryptoDir()
+	if len(dir) == 0 {
+		return errors.New("crypto dir is not defined")
+	}
+	if !exist {
+		if err := os.MkdirAll(dir, 0700); err != nil {
+			return err
+		}
+	}
+
+	if len(pub) != t.PublicSize || len(priv) != t.PrivateSize {
+		return errors.New("wrong key size")
+	}
+	pubFile := GetFullFileName(name + t.PublicExt)
+	privFile := GetFullFileName(name + t.PrivateExt)
+	for _, f := range []string{pubFile, privFile} {
+		if _, err := os.Stat(f); err == nil {
+			return fmt.Errorf("file [%s] already exists", f)
+		}
+	}
+
+	fmt.Print("private key protection, ")
+	key, err := GetPassword(flags)
+	if err != nil {
+		return err
+	}
+	defer crutils.AnnihilateData(key)
+
+	h := crutils.NewHeader(crutils.ModeMain)
+	h.SetKdf(crutils.KdfArgon2id)
+	p := make([]byte, len(priv))
+	copy(p, priv)
+	encrypted, err := crutils.EncryptWithHeader(h, key, p, nil)
+	if err != nil {
+		return err
+	}
+
+	if err = writeNewFile(privFile, encrypted, 0600); err != nil {
+		return err
+	}
+	return writeNewFile(pubFile, []byte(hex.EncodeToString(pub)+"\n"), 0644)
+}
+
+func LoadPublicKey(t KeyType, name string) ([]byte, error) {
+	data, err := ioutil.ReadFile(getKeyFileName(name, t.PublicExt))
+	if err != nil {
+		return nil, err
+	}
+	pub, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil {
+		return nil, err
+	}
+	if len(pub) != t.PublicSize {
+		return nil, fmt.Errorf("wrong public key size: %d", len(pub))
+	}
+	return pub, nil
+}
+
+// don't forget to annihilate the result!
+func LoadPrivateKey(t KeyType, name string, flags string) ([]byte, error) {
+	data, err := ioutil.ReadFile(getKeyFileName(name, t.PrivateExt))
+	if err != nil {
+		return nil, err
+	}
+
+	fmt.Print("private key decryption, ")
+	key, err := GetPassword(flags)
+	if err != nil {
+		return nil, err
+	}
+	defer crutils.AnnihilateData(key)
+
+	priv, _, err := crutils.Decrypt(key, data)
+	if err != nil {
+		return nil, fmt.Errorf("failed to decrypt private key: %s", err.Error())
+	}
+	if len(priv) != t.PrivateSize {
+		crutils.AnnihilateData(priv)
+		return nil, fmt.Errorf("wrong private key size: %d", len(priv))
+	}
+	return priv, nil
+}
+
+func GetKeyName(legend string) string {
+	if Batch {
+		return os.Getenv(EnvKeyName)
+	}
+	fmt.Printf("please enter %s name: ", legend)
+	return string(terminal.PlainTextInput())
+}
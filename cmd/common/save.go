@@ -0,0 +1,120 @@
+package common
+
+import (
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+)
+
+// Safe saving: the data is written to the temporary file in the same directory (mode 0600),
+// which is synced and then atomically renamed, so that the existing file is never left half-written.
+// Optionally, the previous version is kept as numbered backup (name.~N~).
+
+const maxBackups = 1000
+
+var (
+	Backup         bool // keep the previous version of the overwritten file
+	AllowOverwrite bool // do not ask for confirmation (e.g. in batch mode)
+)
+
+func FileExists(filename string) bool {
+	_, err := os.Lstat(filename)
+	return err == nil
+}
+
+// asks for confirmation if the file already exists
+func ConfirmOverwrite(filename string) bool {
+	if filename == StdStream || AllowOverwrite || !FileExists(filename) {
+		return true
+	}
+	return Confirm(fmt.Sprintf("File [%s] already exists. Do you want to overwrite it?", filename))
+}
+
+// if the file is a symlink, then the file it points to is replaced, not the link
+func resolveTarget(filename string) string {
+	if target, err := filepath.EvalSymlinks(filename); err == nil {
+		return target
+	}
+	return filename
+}
+
+// the temporary file must be either committed or discarded;
+// it is created in the directory of the target file, so that the rename is atomic
+func CreateTemp(filename string) (*os.File, error) {
+	dir, base := filepath.Split(resolveTarget(filename))
+	if len(dir) == 0 {
+		dir = "."
+	}
+	return ioutil.TempFile(dir, "."+base+".tmp") // mode 0600
+}
+
+func DiscardTemp(f *os.File) {
+	f.Close()
+	os.Remove(f.Name())
+}
+
+// syncs and closes the temporary file, and then renames it
+func CommitTemp(f *os.File, filename string) error {
+	err := f.Sync()
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		target := resolveTarget(filename)
+		if filepath.Dir(target) != filepath.Dir(f.Name()) {
+			err = fmt.Errorf("symlink [%s] changed during saving", filename)
+		}
+		filename = target
+	}
+	if err == nil {
+		if Backup && FileExists(filename) {
+			err = makeBackup(filename)
+		}
+	}
+	if err == nil {
+		err = os.Rename(f.Name(), filename)
+	}
+	if err != nil {
+		os.Remove(f.Name())
+		return err
+	}
+	syncDir(filepath.Dir(filename))
+	return nil
+}
+
+// hard link preserves the original file until it is replaced by rename
+func makeBackup(filename string) error {
+	for i := 1; i < maxBackups; i++ {
+		name := fmt.Sprintf("%s.~%d~", filename, i)
+		if FileExists(name) {
+			continue
+		}
+		if err := os.Link(filename, name); err != nil {
+			return fmt.Errorf("failed to create backup: %s", err.Error())
+		}
+		fmt.Printf("backup saved: %s\n", name)
+		return nil
+	}
+	return fmt.Errorf("too many backups of [%s]", filename)
+}
+
+// the rename is durable only after the directory is synced (best effort)
+func syncDir(dir string) {
+	if d, err := os.Open(dir); err == nil {
+		d.Sync()
+		d.Close()
+	}
+}
+
+func WriteFileAtomic(filename string, data []byte) error {
+	f, err := CreateTemp(filename)
+	if err != nil {
+		return err
+	}
+	if _, err = f.Write(data); err != nil {
+		DiscardTemp(f)
+		return err
+	}
+	return CommitTemp(f, filename)
+}
@@ -0,0 +1,44 @@
+package common
+
+import (
+	"bufio"
+	"io/ioutil"
+	"os"
+
+	"github.com/gluk256/crypto/terminal"
+)
+
+// Standard streams: "-" as srcFile means stdin, as dstFile means stdout, e.g. tar c dir | xcry bf - - | ssh ...
+// If stdout is used for data, all the messages (including prompts and the proof of destruction) go to stderr.
+// If stdin is used for data, the interactive input is taken from the terminal (/dev/tty).
+
+const StdStream = "-"
+
+var (
+	Stdin  = bufio.NewReader(os.Stdin) // allows to peek the data
+	Stdout = os.Stdout                 // the original stdout, which remains intact
+)
+
+// must be called before any output
+func SetupStdStreams(srcFile string, dstFile string) error {
+	if dstFile == StdStream {
+		os.Stdout = os.Stderr // all the fmt.Print* calls are redirected
+	}
+	if srcFile == StdStream && !Batch {
+		return terminal.UseTty()
+	}
+	return nil
+}
+
+// don't forget to annihilate the result!
+func LoadData(filename string) ([]byte, error) {
+	if filename == StdStream {
+		return ioutil.ReadAll(Stdin)
+	}
+	return ioutil.ReadFile(filename)
+}
+
+func writeStdout(data []byte) error {
+	_, err := Stdout.Write(data)
+	return err
+}
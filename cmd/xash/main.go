@@ -1,8 +1,10 @@
 package main
 
 import (
+	"crypto/sha256"
 	"fmt"
-	"io/ioutil"
+	"hash"
+	"io"
 	"os"
 	"strings"
 
@@ -31,13 +33,31 @@ func help() {
 	fmt.Println("\t -h help")
 }
 
-func readFile(name string) []byte {
-	b, err := ioutil.ReadFile(name)
+func newHash() hash.Hash {
+	if keccakHash {
+		return new(keccak.Keccak512)
+	}
+	return sha256.New()
+}
+
+// the file is streamed, so that its size is not limited by the available memory
+func hashFile(name string) []byte {
+	f, err := os.Open(name)
 	if err != nil {
 		fmt.Printf("Error: can not read file [%s]\n", name)
 		os.Exit(0)
 	}
-	return b
+	defer f.Close()
+
+	h := newHash()
+	if _, err = io.Copy(h, f); err != nil {
+		fmt.Printf("Error: can not read file [%s]: %s\n", name, err.Error())
+		os.Exit(0)
+	}
+	if keccakHash {
+		return h.Sum(nil)[:32] // same as keccak.Digest(src, 32)
+	}
+	return h.Sum(nil)
 }
 
 func processFlags() bool {
@@ -71,10 +91,8 @@ func main() {
 	}
 
 	if fileMode {
-		src = readFile(string(src))
-	}
-
-	if keccakHash {
+		hash = hashFile(string(src))
+	} else if keccakHash {
 		hash = keccak.Digest(src, 32)
 	} else {
 		hash = crutils.Sha2(src)
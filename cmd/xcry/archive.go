@@ -0,0 +1,250 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+
+	"github.com/gluk256/crypto/crutils"
+)
+
+// Directory mode ('D'): the whole tree is packed into tar archive (in memory), which is then encrypted as usual.
+// Only directories and regular files are supported; names, modes and mtimes are preserved,
+// while the owner info is dropped. On decryption the tree is restored into the target directory.
+// Existing files are never overwritten, and the entries pointing outside the target directory are refused.
+
+const tarBlockSize = 512
+
+type archiveEntry struct {
+	path string
+	info os.FileInfo
+}
+
+func isArchive(data []byte) bool {
+	const magicOffset = 257
+	return len(data) >= tarBlockSize && bytes.HasPrefix(data[magicOffset:], []byte("ustar"))
+}
+
+func collectEntries(dir string) (entries []archiveEntry, size int, err error) {
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if path == dir {
+			return nil
+		}
+		if !info.IsDir() && !info.Mode().IsRegular() {
+			fmt.Printf("Warning: skipping [%s], only regular files and directories are supported\n", path)
+			return nil
+		}
+		entries = append(entries, archiveEntry{path: path, info: info})
+		size += tarBlockSize * 3 // header, including possible extended header for the long names
+		size += (int(info.Size()) + tarBlockSize - 1) / tarBlockSize * tarBlockSize
+		return nil
+	})
+	return entries, size + tarBlockSize*2, err
+}
+
+// don't forget to annihilate the result!
+func packDirectory(dir string) ([]byte, error) {
+	dir = filepath.Clean(dir)
+	if info, err := os.Stat(dir); err != nil {
+		return nil, err
+	} else if !info.IsDir() {
+		return nil, fmt.Errorf("[%s] is not a directory", dir)
+	}
+	entries, size, err := collectEntries(dir)
+	if err != nil {
+		return nil, err
+	}
+	if len(entries) == 0 {
+		return nil, errors.New("empty directory")
+	}
+
+	var buf bytes.Buffer
+	buf.Grow(size) // avoid reallocation of sensitive data
+	w := tar.NewWriter(&buf)
+	for _, e := range entries {
+		if err = addEntry(w, dir, e); err != nil {
+			crutils.AnnihilateData(buf.Bytes())
+			return nil, err
+		}
+	}
+	if err = w.Close(); err != nil {
+		crutils.AnnihilateData(buf.Bytes())
+		return nil, err
+	}
+	fmt.Printf("Packed %d entries\n", len(entries))
+	return buf.Bytes(), nil
+}
+
+func addEntry(w *tar.Writer, dir string, e archiveEntry) error {
+	rel, err := filepath.Rel(dir, e.path)
+	if err != nil {
+		return err
+	}
+	hdr, err := tar.FileInfoHeader(e.info, "")
+	if err != nil {
+		return err
+	}
+	hdr.Name = filepath.ToSlash(rel)
+	if e.info.IsDir() {
+		hdr.Name += "/"
+	}
+	hdr.Uid, hdr.Gid, hdr.Uname, hdr.Gname = 0, 0, "", ""
+	hdr.AccessTime, hdr.ChangeTime = time.Time{}, time.Time{}
+	if err = w.WriteHeader(hdr); err != nil {
+		return err
+	}
+	if e.info.IsDir() {
+		return nil
+	}
+
+	content, err := ioutil.ReadFile(e.path)
+	if err == nil {
+		if int64(len(content)) != hdr.Size {
+			err = fmt.Errorf("file [%s] changed during packing", e.path)
+		} else {
+			_, err = w.Write(content)
+		}
+	}
+	crutils.AnnihilateData(content)
+	return err
+}
+
+// returns the path inside the target directory, or error if the entry name is not acceptable
+func getEntryPath(dir string, name string) (string, error) {
+	clean := filepath.Clean(filepath.FromSlash(name))
+	if len(name) == 0 || filepath.IsAbs(clean) || clean == "." || clean == ".." ||
+		strings.HasPrefix(clean, ".."+string(filepath.Separator)) {
+		return "", fmt.Errorf("illegal entry name [%s]", name)
+	}
+	for _, part := range strings.Split(filepath.ToSlash(name), "/") {
+		if part == ".." {
+			return "", fmt.Errorf("illegal entry name [%s]", name)
+		}
+	}
+
+	// make sure that the existing path does not lead outside via symlinks
+	path := dir
+	for _, part := range strings.Split(clean, string(filepath.Separator)) {
+		path = filepath.Join(path, part)
+		info, err := os.Lstat(path)
+		if os.IsNotExist(err) {
+			break
+		} else if err != nil {
+			return "", err
+		} else if info.Mode()&os.ModeSymlink != 0 {
+			return "", fmt.Errorf("entry [%s] leads through symlink", name)
+		}
+	}
+	return filepath.Join(dir, clean), nil
+}
+
+// creates the missing directories (as opposed to os.MkdirAll) and records them
+func makeDirs(path string, created map[string]bool) error {
+	if info, err := os.Lstat(path); err == nil {
+		if !info.IsDir() {
+			return fmt.Errorf("[%s] is not a directory", path)
+		}
+		return nil
+	} else if !os.IsNotExist(err) {
+		return err
+	}
+	if err := makeDirs(filepath.Dir(path), created); err != nil {
+		return err
+	}
+	if err := os.Mkdir(path, 0700); err != nil {
+		return err
+	}
+	created[path] = true
+	return nil
+}
+
+func unpackArchive(dir string, data []byte) error {
+	if !isArchive(data) {
+		return errors.New("the data is not an archive")
+	}
+	dir = filepath.Clean(dir)
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return err
+	}
+
+	var dirs []*tar.Header
+	files := 0
+	seen := make(map[string]bool)
+	created := make(map[string]bool)
+	r := tar.NewReader(bytes.NewReader(data))
+	for {
+		hdr, err := r.Next()
+		if err == io.EOF {
+			break
+		} else if err != nil {
+			return err
+		}
+		path, err := getEntryPath(dir, hdr.Name)
+		if err != nil {
+			return err
+		}
+		if seen[path] {
+			return fmt.Errorf("duplicate entry [%s]", hdr.Name)
+		}
+		seen[path] = true
+
+		switch hdr.Typeflag {
+		case tar.TypeDir:
+			if err = makeDirs(path, created); err != nil {
+				return err
+			}
+			dirs = append(dirs, hdr)
+		case tar.TypeReg:
+			if err = extractFile(r, hdr, path, created); err != nil {
+				return err
+			}
+			files++
+		default:
+			return fmt.Errorf("unsupported entry type [%s]", hdr.Name)
+		}
+	}
+
+	// directories are updated after their content is written (pre-existing ones are left intact)
+	for i := len(dirs) - 1; i >= 0; i-- {
+		path, _ := getEntryPath(dir, dirs[i].Name)
+		if !created[path] {
+			continue
+		}
+		if err := os.Chmod(path, dirs[i].FileInfo().Mode().Perm()); err != nil {
+			return err
+		}
+		if err := os.Chtimes(path, dirs[i].ModTime, dirs[i].ModTime); err != nil {
+			return err
+		}
+	}
+	fmt.Printf("Unpacked %d files and %d directories into [%s]\n", files, len(dirs), dir)
+	return nil
+}
+
+func extractFile(r io.Reader, hdr *tar.Header, path string, created map[string]bool) error {
+	if err := makeDirs(filepath.Dir(path), created); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_EXCL, hdr.FileInfo().Mode().Perm())
+	if err != nil {
+		return err
+	}
+	_, err = io.Copy(f, r)
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err == nil {
+		err = os.Chtimes(path, hdr.ModTime, hdr.ModTime)
+	}
+	return err
+}
@@ -0,0 +1,176 @@
+package main
+
+import (
+	"archive/tar"
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+type testEntry struct {
+	name     string
+	typeflag byte
+	link     string
+	content  string
+	mode     int64
+}
+
+func makeArchive(t *testing.T, entries []testEntry) []byte {
+	var buf bytes.Buffer
+	w := tar.NewWriter(&buf)
+	for _, e := range entries {
+		hdr := &tar.Header{
+			Name:     e.name,
+			Typeflag: e.typeflag,
+			Linkname: e.link,
+			Mode:     e.mode,
+			Size:     int64(len(e.content)),
+			ModTime:  time.Unix(1000000000, 0),
+		}
+		if e.typeflag != tar.TypeReg {
+			hdr.Size = 0
+		}
+		if err := w.WriteHeader(hdr); err != nil {
+			t.Fatal(err)
+		}
+		if hdr.Size > 0 {
+			if _, err := w.Write([]byte(e.content)); err != nil {
+				t.Fatal(err)
+			}
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func makeTempDir(t *testing.T) string {
+	dir, err := ioutil.TempDir("", "xcry-archive")
+	if err != nil {
+		t.Fatal(err)
+	}
+	return dir
+}
+
+func TestGetEntryPath(t *testing.T) {
+	dir := makeTempDir(t)
+	defer os.RemoveAll(dir)
+
+	good := []string{"a", "a/b", "a/b/", "./a", "a//b"}
+	for _, name := range good {
+		if _, err := getEntryPath(dir, name); err != nil {
+			t.Fatalf("entry [%s] refused: %s", name, err)
+		}
+	}
+	bad := []string{"", ".", "..", "../x", "a/../../x", "a/../b", "/x", "/etc/passwd"}
+	for _, name := range bad {
+		if _, err := getEntryPath(dir, name); err == nil {
+			t.Fatalf("entry [%s] accepted", name)
+		}
+	}
+
+	if err := os.Symlink(os.TempDir(), filepath.Join(dir, "link")); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := getEntryPath(dir, "link/x"); err == nil {
+		t.Fatal("entry through symlink accepted")
+	}
+}
+
+func TestUnpackArchive(t *testing.T) {
+	dir := makeTempDir(t)
+	defer os.RemoveAll(dir)
+	dst := filepath.Join(dir, "dst")
+
+	data := makeArchive(t, []testEntry{
+		{name: "sub/", typeflag: tar.TypeDir, mode: 0750},
+		{name: "sub/file", typeflag: tar.TypeReg, content: "content", mode: 0640},
+		{name: "top", typeflag: tar.TypeReg, content: "top", mode: 0600},
+	})
+	if err := unpackArchive(dst, data); err != nil {
+		t.Fatal(err)
+	}
+	if b, _ := ioutil.ReadFile(filepath.Join(dst, "sub", "file")); string(b) != "content" {
+		t.Fatalf("wrong content [%s]", b)
+	}
+	info, err := os.Stat(filepath.Join(dst, "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0750 || !info.ModTime().Equal(time.Unix(1000000000, 0)) {
+		t.Fatalf("directory attributes are not restored: %o %v", info.Mode().Perm(), info.ModTime())
+	}
+
+	// existing files are never overwritten
+	if err = unpackArchive(dst, data); err == nil {
+		t.Fatal("existing file overwritten")
+	}
+}
+
+func TestUnpackExistingDir(t *testing.T) {
+	dir := makeTempDir(t)
+	defer os.RemoveAll(dir)
+	if err := os.Mkdir(filepath.Join(dir, "sub"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	data := makeArchive(t, []testEntry{
+		{name: "./", typeflag: tar.TypeDir, mode: 0700},
+		{name: "sub/", typeflag: tar.TypeDir, mode: 0700},
+		{name: "sub/file", typeflag: tar.TypeReg, content: "x", mode: 0600},
+	})
+	if err := unpackArchive(dir, data); err == nil {
+		t.Fatal("root entry accepted")
+	}
+	data = makeArchive(t, []testEntry{
+		{name: "sub/", typeflag: tar.TypeDir, mode: 0700},
+		{name: "sub/file", typeflag: tar.TypeReg, content: "x", mode: 0600},
+	})
+	if err := unpackArchive(dir, data); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "sub"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if info.Mode().Perm() != 0755 {
+		t.Fatalf("existing directory is modified: %o", info.Mode().Perm())
+	}
+}
+
+func TestUnpackRefused(t *testing.T) {
+	outside := makeTempDir(t)
+	defer os.RemoveAll(outside)
+
+	archives := [][]testEntry{
+		{{name: "../x", typeflag: tar.TypeReg, content: "x", mode: 0600}},
+		{{name: filepath.Join(outside, "x"), typeflag: tar.TypeReg, content: "x", mode: 0600}},
+		{
+			{name: "link", typeflag: tar.TypeSymlink, link: outside, mode: 0777},
+			{name: "link/x", typeflag: tar.TypeReg, content: "x", mode: 0600},
+		},
+		{
+			{name: "a", typeflag: tar.TypeReg, content: "x", mode: 0600},
+			{name: "a", typeflag: tar.TypeReg, content: "y", mode: 0600},
+		},
+		{
+			{name: "d/", typeflag: tar.TypeDir, mode: 0700},
+			{name: "d", typeflag: tar.TypeDir, mode: 0777},
+		},
+	}
+	for i, entries := range archives {
+		dir := makeTempDir(t)
+		err := unpackArchive(dir, makeArchive(t, entries))
+		os.RemoveAll(dir)
+		if err == nil {
+			t.Fatalf("archive number %d accepted", i)
+		}
+		if files, _ := ioutil.ReadDir(outside); len(files) != 0 {
+			t.Fatalf("file written outside, archive number %d", i)
+		}
+	}
+}
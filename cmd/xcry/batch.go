@@ -0,0 +1,50 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gluk256/crypto/cmd/common"
+	"github.com/gluk256/crypto/crutils"
+)
+
+// non-interactive version of processEncryption/processDecryption (see common.Batch)
+func runBatch(flags string, srcFile string, dstFile string) error {
+	data := getData(flags, srcFile)
+	if len(data) == 0 {
+		return errors.New("no data")
+	}
+	defer crutils.AnnihilateData(data)
+
+	if strings.Contains(flags, "d") {
+		decrypted, steg, err := decrypt(flags, data, false)
+		defer crutils.AnnihilateData(decrypted)
+		defer crutils.AnnihilateData(steg)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return err
+		}
+		return saveDecrypted(dstFile, decrypted)
+	}
+
+	var key []byte
+	var err error
+	if strings.Contains(flags, "u") {
+		key, err = common.LoadPublicKey(common.EncryptionKey, common.GetKeyName("recipient's public key"))
+	} else {
+		key, err = common.GetPassword(flags)
+	}
+	var encrypted []byte
+	if err == nil {
+		encrypted, err = encrypt(flags, key, data, nil)
+	}
+	crutils.AnnihilateData(key)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return err
+	}
+	err = saveEncrypted(strings.Contains(flags, "A"), dstFile, encrypted)
+	crutils.AnnihilateData(encrypted)
+	return err
+}
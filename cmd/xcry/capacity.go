@@ -0,0 +1,75 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"os"
+
+	"github.com/gluk256/crypto/crutils"
+)
+
+// Capacity planner ('c'): reports how the files fit into each other as nested steganographic layers,
+// without reading the content or asking for any keys. The first file is the face content.
+
+func getFileSizes(names []string) ([]int, error) {
+	sizes := make([]int, 0, len(names))
+	for _, name := range names {
+		info, err := os.Stat(name)
+		if err != nil {
+			return nil, err
+		}
+		if !info.Mode().IsRegular() {
+			return nil, fmt.Errorf("[%s] is not a regular file", name)
+		}
+		sizes = append(sizes, int(info.Size()))
+	}
+	return sizes, nil
+}
+
+// each hidden file is reported in two ways: as plain content (nested layers, e.g. in xed),
+// and as already encrypted file (inserted with 'i'), since the encrypted file needs less space.
+func reportCapacity(names []string) error {
+	if len(names) == 0 {
+		return errors.New("face file is missing")
+	}
+	sizes, err := getFileSizes(names)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("face [%s]: %d bytes, capacity for the hidden plain content: %d bytes, for the encrypted file: %d bytes\n",
+		names[0], sizes[0], crutils.StegCapacity(sizes[0]), crutils.PaddedSize(sizes[0]))
+
+	fits := true
+	for i, f := range crutils.PlanSteg(sizes...) {
+		fmt.Printf("[%s] in [%s]:\n", names[i+1], names[i])
+		fmt.Printf("\tas plain content: %d bytes, capacity %d bytes, headroom %d bytes, min size of [%s]: %d bytes",
+			f.Hidden, f.Capacity, f.Headroom, names[i], f.MinFace)
+		printFit(f.Fits())
+		fits = fits && f.Fits()
+		fmt.Printf("\tas encrypted file ('i'): %d bytes, capacity %d bytes, min size of [%s]: %d bytes",
+			f.Hidden, crutils.PaddedSize(f.Face), names[i], crutils.MinFaceSizeEncrypted(f.Hidden))
+		printFit(crutils.FitsEncrypted(f.Face, f.Hidden)) // the same check as in checkStegFit()
+	}
+	if len(sizes) > 1 && fits {
+		fmt.Printf("all the files fit as plain content, encrypted size: %d bytes\n", crutils.EncryptedSize(sizes[0]))
+	}
+	return nil
+}
+
+func printFit(fits bool) {
+	if fits {
+		fmt.Println()
+	} else {
+		fmt.Println(" >>> does not fit <<<")
+	}
+}
+
+// the steganographic content is already encrypted
+func checkStegFit(faceSize int, stegSize int) bool {
+	if crutils.FitsEncrypted(faceSize, stegSize) {
+		return true
+	}
+	fmt.Printf("The face content is too small for the steganographic content [%d vs. %d], the minimum size is %d bytes.\n",
+		faceSize, stegSize, crutils.MinFaceSizeEncrypted(stegSize))
+	return false
+}
@@ -2,8 +2,8 @@ package main
 
 import (
 	"encoding/hex"
+	"errors"
 	"fmt"
-	"io/ioutil"
 	"os"
 	"strings"
 
@@ -14,17 +14,29 @@ import (
 
 var Delimiter = "————————————————————————————————————————————————————————————————————————————————————————————————————"
 
+var directoryMode bool // see archive.go
+
+var errWrongFlags = errors.New("wrong flags")
+
 func help() {
 	fmt.Printf("xcry v.1.%d.1 \n", crutils.CipherVersion)
 	fmt.Println("encrypt/decrypt a file")
-	fmt.Println("USAGE: xcry flags [srcFile] [dstFile]")
+	fmt.Println("USAGE: xcry flags [srcFile] [dstFile] ('-' means stdin/stdout)")
 	fmt.Println("\t -h help")
 	fmt.Println("\t -s secure password/text input")
 	fmt.Println("\t -x extra secure password/text input")
+	fmt.Println("\t -k combine password with keyfiles (certificate, arbitrary files, hex secret)")
 	fmt.Println("\t -f save to file")
+	fmt.Println("\t -o overwrite existing file without confirmation")
+	fmt.Println("\t -B keep the overwritten file as numbered backup (dstFile.~N~)")
+	fmt.Println("\t -A armored output (base64 with BEGIN/END lines), armored input is detected automatically")
 
 	fmt.Println("\t -e encrypt (default mode)")
 	fmt.Println("\t\t -r random password")
+	fmt.Println("\t\t -v add versioned header (incompatible with steganographic content)")
+	fmt.Println("\t\t -a use memory-hard KDF argon2id (implies -v)")
+	fmt.Println("\t\t -y use memory-hard KDF scrypt (implies -v)")
+	fmt.Println("\t\t -z compress before encryption (implies -v)")
 
 	fmt.Println("\t -d decrypt")
 	fmt.Println("\t\t -p output decrypted content as text, don't save")
@@ -32,12 +44,21 @@ func help() {
 	fmt.Println("\t\t -G interactive grep with secure input")
 
 	fmt.Println("\t -i insert file content into another file as steganographic content")
+	fmt.Println("\t -c report steganographic capacity: xcry c faceFile hiddenFile [hiddenFile...] (nested layers)")
+	fmt.Println("\t -u encrypt with recipient's public key (decryption with private key is detected automatically)")
+	fmt.Println("\t -n generate new key pair (srcFile is the key name)")
+	fmt.Println("\t -m encrypt for multiple recipients (passwords and/or public keys)")
+	fmt.Println("\t -M manage recipients of multi-recipient file (add/remove slots)")
+	fmt.Println("\t -D directory mode: srcDir is packed into archive before encryption, or dstDir is restored after decryption")
 
 	fmt.Println("\t -t enter text (password mode)")
 	fmt.Println("\t -T enter text (plain text mode)")
+	fmt.Println("\t -b batch mode (non-interactive, requires -f, srcFile and dstFile; exit code 1 on failure)")
+	common.PrintBatchHelp()
 }
 
-func processCommandArgs() (flags string, srcFile string, dstFile string) {
+// returns empty flags if there is nothing to do (e.g. help), or error if the args are wrong
+func processCommandArgs() (flags string, srcFile string, dstFile string, err error) {
 	if len(os.Args) == 1 {
 		flags = "h"
 	}
@@ -53,23 +74,51 @@ func processCommandArgs() (flags string, srcFile string, dstFile string) {
 
 	if strings.Contains(flags, "h") || strings.Contains(flags, "?") {
 		help()
-		return "", srcFile, dstFile
+		return "", srcFile, dstFile, nil
 	}
 
 	if strings.Contains(flags, "d") && strings.Contains(flags, "r") {
 		fmt.Println("Random password ('r') is incompatible with decryption ('d').")
-		fmt.Println("ERROR: wrong flags.")
-		return "", srcFile, dstFile
+		return "", srcFile, dstFile, errWrongFlags
+	}
+
+	if strings.Contains(flags, "u") && (strings.Contains(flags, "d") || strings.Contains(flags, "i")) {
+		fmt.Println("Public key encryption ('u') is incompatible with decryption ('d') and steganography ('i').")
+		return "", srcFile, dstFile, errWrongFlags
+	}
+
+	if strings.Contains(flags, "m") && (strings.Contains(flags, "d") || strings.Contains(flags, "i") || strings.Contains(flags, "u")) {
+		fmt.Println("Multi-recipient encryption ('m') is incompatible with decryption ('d'), steganography ('i') and public key ('u').")
+		return "", srcFile, dstFile, errWrongFlags
+	}
+
+	if strings.Contains(flags, "n") && len(srcFile) == 0 {
+		return "", srcFile, dstFile, errors.New("key name is missing")
+	}
+
+	common.AllowOverwrite = strings.Contains(flags, "o")
+	common.Backup = strings.Contains(flags, "B")
+
+	if strings.Contains(flags, "b") {
+		if strings.ContainsAny(flags, "tTiMnmrgG") || !strings.Contains(flags, "f") || len(dstFile) == 0 {
+			fmt.Println("Batch mode ('b') requires flag 'f', srcFile and dstFile, and is incompatible with interactive flags ('tTiMnmrgG').")
+			return "", srcFile, dstFile, errWrongFlags
+		}
+		common.Batch = true
+	}
+
+	if strings.Contains(flags, "D") && strings.ContainsAny(flags, "tTiMn") {
+		fmt.Println("Directory mode ('D') is incompatible with text input ('t', 'T'), steganography ('i') and keys management ('M', 'n').")
+		return "", srcFile, dstFile, errWrongFlags
 	}
 
 	if strings.Contains(flags, "t") || strings.Contains(flags, "T") {
 		if len(os.Args) > 2 {
-			fmt.Println("ERROR: flag -t is incompatible with param srcFile")
-			return "", srcFile, dstFile
+			return "", srcFile, dstFile, errors.New("flag -t is incompatible with param srcFile")
 		}
 	}
 
-	return flags, srcFile, dstFile
+	return flags, srcFile, dstFile, nil
 }
 
 func enterText(flags string) (res []byte) {
@@ -79,7 +128,7 @@ func enterText(flags string) (res []byte) {
 	} else if strings.Contains(flags, "x") {
 		res = terminal.SecureInput(true)
 	} else if strings.Contains(flags, "T") {
-		res = terminal.PlainTextInput()
+		res = common.CompleteArmoredInput(terminal.PlainTextInput())
 	} else {
 		res = terminal.PasswordModeInput()
 	}
@@ -101,15 +150,68 @@ func enterText(flags string) (res []byte) {
 func getData(flags string, srcFile string) (data []byte) {
 	if strings.Contains(flags, "t") || strings.Contains(flags, "T") {
 		data = enterText(flags)
+	} else if strings.Contains(flags, "D") && !strings.Contains(flags, "d") {
+		data = loadDirectory(srcFile)
 	} else {
 		data = loadDataFromFile(srcFile)
 	}
+	if (strings.Contains(flags, "d") || strings.Contains(flags, "M")) && common.IsArmored(data) {
+		data = dearmor(data)
+	}
 	if len(data) == 0 {
 		fmt.Println("Error: empty data")
 	}
 	return data
 }
 
+func dearmor(data []byte) []byte {
+	_, fields, res, err := common.Dearmor(data)
+	crutils.AnnihilateData(data)
+	if err != nil {
+		fmt.Printf("Error decoding armored data: %s\n", err.Error())
+		return nil
+	}
+	if mode, ok := fields["Mode"]; ok {
+		fmt.Printf("Loaded armored data, mode %s\n", mode)
+	}
+	return res
+}
+
+func saveEncrypted(armored bool, dstFile string, encrypted []byte) error {
+	if armored {
+		return common.SaveData(dstFile, common.ArmorCiphertext(encrypted))
+	}
+	return common.SaveData(dstFile, encrypted)
+}
+
+func loadDirectory(dir string) []byte {
+	if len(dir) == 0 {
+		fmt.Print("source directory, ")
+		dir = common.GetFileName()
+	}
+	data, err := packDirectory(dir)
+	if err != nil {
+		fmt.Printf("Failed to pack directory: %s\n", err.Error())
+		return nil
+	}
+	return data
+}
+
+func saveDecrypted(dstFile string, decrypted []byte) error {
+	if !directoryMode || !isArchive(decrypted) || dstFile == common.StdStream {
+		return common.SaveData(dstFile, decrypted)
+	}
+	if len(dstFile) == 0 {
+		fmt.Print("target directory, ")
+		dstFile = common.GetFileName()
+	}
+	err := unpackArchive(dstFile, decrypted)
+	if err != nil {
+		fmt.Printf("Failed to unpack archive: %s\n", err.Error())
+	}
+	return err
+}
+
 func loadDataFromFile(filename string) []byte {
 	for i := 0; i < 8; i++ {
 		if len(filename) == 0 || i > 0 {
@@ -118,7 +220,7 @@ func loadDataFromFile(filename string) []byte {
 				return nil
 			}
 		}
-		data, err := ioutil.ReadFile(filename)
+		data, err := common.LoadData(filename)
 		if err != nil {
 			fmt.Printf("Failed to load data: %s\n", err.Error())
 			fmt.Println("Please try again.")
@@ -132,18 +234,49 @@ func loadDataFromFile(filename string) []byte {
 }
 
 func main() {
-	flags, srcFile, dstFile := processCommandArgs()
+	flags, srcFile, dstFile, err := processCommandArgs()
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err.Error())
+		common.Exit(err)
+	}
 	if len(flags) == 0 {
 		return
 	}
 
+	if strings.Contains(flags, "c") {
+		if err := reportCapacity(os.Args[2:]); err != nil {
+			fmt.Printf("ERROR: %s\n", err.Error())
+			common.Exit(err)
+		}
+		return
+	}
+
+	if err := common.SetupStdStreams(srcFile, dstFile); err != nil {
+		fmt.Printf("ERROR: %s\n", err.Error())
+		common.Exit(err)
+	}
+	directoryMode = strings.Contains(flags, "D")
+	if common.Batch {
+		err := runBatch(flags, srcFile, dstFile)
+		crutils.ProveDataDestruction()
+		common.Exit(err)
+		return
+	}
+
 	defer crutils.ProveDataDestruction()
+	if strings.Contains(flags, "n") {
+		generateKeyPair(flags, srcFile)
+		return
+	}
+
 	data := getData(flags, srcFile)
 	if len(data) == 0 {
 		return
 	}
 
-	if strings.Contains(flags, "i") {
+	if strings.Contains(flags, "M") {
+		manageRecipients(flags, dstFile, data)
+	} else if strings.Contains(flags, "i") {
 		insertSteg(flags, dstFile, data)
 	} else if strings.Contains(flags, "d") {
 		processDecryption(flags, dstFile, data, false)
@@ -155,18 +288,54 @@ func main() {
 }
 
 func insertSteg(flags string, dstFile string, steg []byte) {
+	if crutils.HasHeader(steg) {
+		fmt.Println("Error: content with header can not be used as steganographic content")
+		return
+	}
 	fmt.Print("loading face content, ")
 	name := common.GetFileName()
 	face := getData("", name)
-	if len(face) != 0 {
+	if len(face) != 0 && checkStegFit(len(face), len(steg)) {
 		processEncryption(flags, dstFile, face, steg)
 	}
+	crutils.AnnihilateData(face)
+}
+
+func generateKeyPair(flags string, name string) {
+	pub, priv, err := crutils.GenerateKeyPair()
+	if err == nil {
+		err = common.SaveKeyPair(common.EncryptionKey, name, pub, priv, flags)
+		crutils.AnnihilateData(priv)
+	}
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+	} else {
+		fmt.Printf("Key pair [%s] saved, public key: %x\n", name, pub)
+	}
+}
+
+func decryptWithPrivateKey(flags string, data []byte) (decrypted []byte, steg []byte, err error) {
+	priv, err := common.LoadPrivateKey(common.EncryptionKey, common.GetKeyName("private key"), flags)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer crutils.AnnihilateData(priv)
+	d := make([]byte, len(data))
+	copy(d, data)
+	return crutils.DecryptWithPrivateKey(priv, d)
 }
 
 func decrypt(flags string, data []byte, unknownSize bool) (decrypted []byte, steg []byte, err error) {
 	var key []byte
 	defer crutils.AnnihilateData(key)
 
+	if !unknownSize && crutils.IsPublicKeyEncrypted(data) {
+		return decryptWithPrivateKey(flags, data)
+	}
+	if !unknownSize && crutils.IsMultiRecipient(data) && common.Confirm("Do you want to decrypt with private key?") {
+		return decryptWithPrivateKey(flags, data)
+	}
+
 	for i := 0; i < 256; i++ {
 		key, err = common.GetPassword(flags)
 		if err != nil {
@@ -197,6 +366,10 @@ func processDecryption(flags string, dstFile string, data []byte, unknownSize bo
 		return
 	}
 
+	if h, err := crutils.ParseHeader(data); err == nil && !unknownSize {
+		fmt.Printf("Header found: %s\n", h)
+	}
+
 	decrypted, steg, err := decrypt(flags, data, unknownSize)
 	defer crutils.AnnihilateData(decrypted)
 	defer crutils.AnnihilateData(steg)
@@ -226,7 +399,7 @@ func processDecryption(flags string, dstFile string, data []byte, unknownSize bo
 	}
 
 	if strings.Contains(flags, "f") {
-		common.SaveData(dstFile, decrypted)
+		saveDecrypted(dstFile, decrypted)
 	} else if strings.Contains(flags, "Y") {
 		processDecryption(flags, dstFile, data, unknownSize) // retry
 	} else {
@@ -234,8 +407,12 @@ func processDecryption(flags string, dstFile string, data []byte, unknownSize bo
 	}
 }
 
-func encrypt(key []byte, data []byte, steg []byte) (res []byte, err error) {
-	if steg == nil {
+func encrypt(flags string, key []byte, data []byte, steg []byte) (res []byte, err error) {
+	if strings.Contains(flags, "u") {
+		res, err = crutils.EncryptToPublicKey(key, data) // the key is public
+	} else if h := common.GetHeader(flags, crutils.ModeMain); h != nil {
+		res, err = crutils.EncryptWithHeader(h, key, data, steg)
+	} else if steg == nil {
 		res, err = crutils.Encrypt(key, data)
 	} else {
 		res, err = crutils.EncryptSteg(key, data, steg)
@@ -246,12 +423,19 @@ func encrypt(key []byte, data []byte, steg []byte) (res []byte, err error) {
 func processEncryption(flags string, dstFile string, data []byte, steg []byte) {
 	var err error
 	var key, encrypted []byte
+	armored := strings.Contains(flags, "A")
 	defer crutils.AnnihilateData(key)
 	defer crutils.AnnihilateData(encrypted)
 
-	key, err = common.GetPassword(flags)
-	if err == nil {
-		encrypted, err = encrypt(key, data, steg)
+	if strings.Contains(flags, "m") {
+		encrypted, err = encryptForRecipients(flags, data)
+	} else if strings.Contains(flags, "u") {
+		key, err = common.LoadPublicKey(common.EncryptionKey, common.GetKeyName("recipient's public key"))
+	} else {
+		key, err = common.GetPassword(flags)
+	}
+	if err == nil && encrypted == nil {
+		encrypted, err = encrypt(flags, key, data, steg)
 		if err != nil {
 			fmt.Printf("ERROR: %s\n", err.Error())
 			fmt.Println("This error is very unusual, further research is required")
@@ -262,7 +446,7 @@ func processEncryption(flags string, dstFile string, data []byte, steg []byte) {
 	if err != nil {
 		fmt.Printf("Error: %s\n", err.Error())
 	} else if strings.Contains(flags, "f") {
-		common.SaveData(dstFile, encrypted)
+		saveEncrypted(armored, dstFile, encrypted)
 		return
 	}
 
@@ -270,7 +454,7 @@ func processEncryption(flags string, dstFile string, data []byte, steg []byte) {
 		fmt.Print("Please enter the command [save_File, Encrypt, Rand_pass, Secure_pass, eXtra_secure, retrY, Quit]: ")
 		flags = string(terminal.PlainTextInput())
 		if strings.Contains(flags, "f") {
-			common.SaveData(dstFile, encrypted)
+			saveEncrypted(armored || strings.Contains(flags, "A"), dstFile, encrypted)
 			return
 		} else if strings.Contains(flags, "q") {
 			return
@@ -281,8 +465,11 @@ func processEncryption(flags string, dstFile string, data []byte, steg []byte) {
 			buf := getData(flags, "")
 			if len(buf) == 0 {
 				return
-			} else if len(buf) < len(encrypted)+4 {
-				fmt.Printf("File size in insufficiant for steg encryption [%d vs. %d]. Please try again.\n", len(buf), len(encrypted)+4)
+			} else if crutils.HasHeader(encrypted) {
+				fmt.Println("Content with header can not be used as steganographic content.")
+				return
+			} else if !checkStegFit(len(buf), len(encrypted)) {
+				fmt.Println("Please try again.")
 			} else {
 				processEncryption(flags, dstFile, buf, encrypted) // recursively encrypt steg content
 			}
@@ -0,0 +1,41 @@
+package main
+
+import (
+	"os"
+	"testing"
+
+	"github.com/gluk256/crypto/cmd/common"
+)
+
+func TestProcessCommandArgs(t *testing.T) {
+	prev := os.Args
+	defer func() {
+		os.Args = prev
+		common.Batch = false
+	}()
+
+	wrong := [][]string{
+		{"xcry", "bf", "src"}, // batch mode without dstFile
+		{"xcry", "b", "src", "dst"},
+		{"xcry", "bfn", "src", "dst"},
+		{"xcry", "dr", "src"},
+		{"xcry", "ud", "src"},
+		{"xcry", "n"},
+		{"xcry", "t", "src"},
+	}
+	for _, args := range wrong {
+		os.Args = args
+		if _, _, _, err := processCommandArgs(); err == nil {
+			t.Fatalf("wrong args accepted: %v", args)
+		}
+	}
+
+	os.Args = []string{"xcry", "h"}
+	if flags, _, _, err := processCommandArgs(); err != nil || len(flags) != 0 {
+		t.Fatalf("help: [%s] %v", flags, err)
+	}
+	os.Args = []string{"xcry", "bf", "src", "dst"}
+	if flags, src, dst, err := processCommandArgs(); err != nil || flags != "bf" || src != "src" || dst != "dst" || !common.Batch {
+		t.Fatalf("batch: [%s] %v", flags, err)
+	}
+}
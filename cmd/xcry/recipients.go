@@ -0,0 +1,142 @@
+package main
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+
+	"github.com/gluk256/crypto/cmd/common"
+	"github.com/gluk256/crypto/crutils"
+	"github.com/gluk256/crypto/terminal"
+)
+
+func getRecipient(flags string) (r crutils.Recipient, err error) {
+	fmt.Print("Please enter the recipient type [Password, public_Key, Done]: ")
+	s := string(terminal.PlainTextInput())
+	if strings.Contains(s, "p") {
+		r.Password, err = common.GetPassword(flags)
+	} else if strings.Contains(s, "k") {
+		r.PublicKey, err = common.LoadPublicKey(common.EncryptionKey, common.GetKeyName("recipient's public key"))
+	}
+	return r, err
+}
+
+func annihilateRecipients(recipients []crutils.Recipient) {
+	for _, r := range recipients {
+		crutils.AnnihilateData(r.Password)
+	}
+}
+
+func encryptForRecipients(flags string, data []byte) ([]byte, error) {
+	var recipients []crutils.Recipient
+	defer func() { annihilateRecipients(recipients) }()
+
+	for len(recipients) < crutils.MaxSlots {
+		r, err := getRecipient(flags)
+		if err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			continue
+		}
+		if r.Password == nil && r.PublicKey == nil {
+			break
+		}
+		recipients = append(recipients, r)
+	}
+	if len(recipients) == 0 {
+		return nil, errors.New("no recipients")
+	}
+
+	h := common.GetHeader(flags, crutils.ModeSlots)
+	return crutils.EncryptForRecipients(h, recipients, data)
+}
+
+// returns either password or private key, which must open one of the slots
+func getUnlockKey(flags string) (key []byte, private bool, err error) {
+	if common.Confirm("Do you want to use private key?") {
+		key, err = common.LoadPrivateKey(common.EncryptionKey, common.GetKeyName("private key"), flags)
+		return key, true, err
+	}
+	key, err = common.GetPassword(flags)
+	return key, false, err
+}
+
+func printSlots(data []byte) error {
+	slots, err := crutils.GetSlots(data)
+	if err != nil {
+		return err
+	}
+	for i, s := range slots {
+		if s == crutils.SlotPassword {
+			fmt.Printf("slot %d: password\n", i)
+		} else {
+			fmt.Printf("slot %d: public key\n", i)
+		}
+	}
+	return nil
+}
+
+func addRecipient(flags string, data []byte) ([]byte, error) {
+	key, private, err := getUnlockKey(flags)
+	if err != nil {
+		return nil, err
+	}
+	defer crutils.AnnihilateData(key)
+
+	r, err := getRecipient(flags)
+	if err != nil {
+		return nil, err
+	}
+	defer crutils.AnnihilateData(r.Password)
+	return crutils.AddRecipient(key, private, data, r)
+}
+
+// the slot table is authenticated, so removing requires the key of one of the slots
+func removeRecipient(flags string, data []byte) ([]byte, error) {
+	i, err := common.GetUint("slot index")
+	if err != nil {
+		return nil, err
+	}
+	key, private, err := getUnlockKey(flags)
+	if err != nil {
+		return nil, err
+	}
+	defer crutils.AnnihilateData(key)
+	return crutils.RemoveRecipient(key, private, data, int(i))
+}
+
+// only the slots are changed, the encrypted data remains untouched
+func manageRecipients(flags string, dstFile string, data []byte) {
+	if !crutils.IsMultiRecipient(data) {
+		fmt.Println("Error: the data is not encrypted for multiple recipients")
+		return
+	}
+
+	for {
+		if err := printSlots(data); err != nil {
+			fmt.Printf("Error: %s\n", err.Error())
+			return
+		}
+		fmt.Print("Please enter the command [Add, Remove, save_File, Quit]: ")
+		cmd := string(terminal.PlainTextInput())
+		if strings.Contains(cmd, "a") {
+			res, err := addRecipient(flags, data)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err.Error())
+			} else {
+				data = res
+			}
+		} else if strings.Contains(cmd, "r") {
+			res, err := removeRecipient(flags, data)
+			if err != nil {
+				fmt.Printf("Error: %s\n", err.Error())
+			} else {
+				data = res
+			}
+		} else if strings.Contains(cmd, "f") {
+			saveEncrypted(strings.Contains(flags, "A"), dstFile, data)
+			return
+		} else if strings.Contains(cmd, "q") {
+			return
+		}
+	}
+}
@@ -4,11 +4,12 @@ import (
 	"fmt"
 	"strings"
 
+	"github.com/gluk256/crypto/cmd/common"
 	"github.com/gluk256/crypto/crutils"
 )
 
 func switchContent() {
-	cur = (cur + 1) % 2
+	cur = (cur + 1) % len(items)
 	cat()
 }
 
@@ -44,6 +45,26 @@ func processCommand(cmd string, prev string) string {
 		switchContent()
 	case "info":
 		info()
+	case "keyfiles":
+		keyfiles = !keyfiles
+		fmt.Printf("keyfiles: %v\n", keyfiles)
+	case "compress":
+		compress = !compress
+		fmt.Printf("compression: %v\n", compress)
+	case "backup":
+		common.Backup = !common.Backup
+		fmt.Printf("backup: %v\n", common.Backup)
+	case "timeout":
+		setIdleTimeout(args)
+	case "lockexit":
+		lockExit = !lockExit
+		fmt.Printf("exit on lock: %v\n", lockExit)
+	case "layer":
+		switchLayer(args)
+	case "layers":
+		listLayers()
+	case "plan":
+		PlanLayers()
 	case "ls":
 		ls()
 	case "cat":
@@ -128,11 +149,19 @@ func processCommand(cmd string, prev string) string {
 		LinesMerge(args)
 	case "s": // editor: split lines
 		LineSplit(args)
+	case "u": // editor: undo
+		Undo()
+	case "U": // editor: redo
+		Redo()
 	case "p": // editor: print lines
 		LinesPrint(args)
 	case "P": // editor: print lines range
 		LinesPrintRange(args)
 	default:
+		if isReplaceCommand(args[0]) {
+			Replace(args)
+			break
+		}
 		fmt.Printf(">>> Wrong command: '%s' [%x] \n", cmd, []byte(cmd))
 	}
 
@@ -144,10 +173,19 @@ func helpInternal() {
 	fmt.Println("help:\t display this help")
 	fmt.Println("rr:\t repeat the previous command")
 	fmt.Println("info:\t display diagnostic info")
+	fmt.Println("keyfiles: toggle keyfiles mode (combine passwords with keyfiles)")
+	fmt.Println("compress: toggle compression before encryption (not applied to steg)")
+	fmt.Println("backup:\t toggle backup of the overwritten files (name.~N~)")
+	fmt.Println("timeout: set idle timeout in minutes for auto-lock (0 disables), e.g. 'timeout 5'")
+	fmt.Println("lock:\t wipe all the content, and ask for the key to decrypt the file again")
+	fmt.Println("lockexit: toggle exit on lock (instead of asking for the key)")
 	fmt.Println("clear:\t wipe the screen")
 	fmt.Println("frame:\t change frame style")
 	fmt.Println("reset:\t reset current content")
-	fmt.Println("sw:\t switch content")
+	fmt.Println("sw:\t switch content (next layer)")
+	fmt.Println("layer:\t go to certain layer, e.g. 'layer 2' (new layer is added after the last one)")
+	fmt.Println("layers:\t list the layers with sizes and capacities")
+	fmt.Println("plan:\t check that the layers fit into each other (capacity, headroom and minimum size)")
 	fmt.Println("ls:\t list current directory contents")
 	fmt.Println("cat:\t print content")
 	fmt.Println("fl:\t file load")
@@ -158,14 +196,14 @@ func helpInternal() {
 	fmt.Println("fDp:\t file load and decrypt (silent, password mode)")
 	fmt.Println("fs:\t file encrypt & save (face)")
 	fmt.Println("fp:\t file encrypt & save (password mode)")
-	fmt.Println("fx:\t file encrypt & save steg")
+	fmt.Println("fx:\t file encrypt & save steg (all the layers, innermost first)")
 	fmt.Println("fpx:\t file encrypt & save steg (face: password mode)")
 	fmt.Println("fpp:\t file encrypt & save steg (password mode)")
 	fmt.Println("cd:\t decrypt loaded content")
 	fmt.Println("cdp:\t decrypt loaded content (password mode)")
 	fmt.Println("cD:\t decrypt loaded content (silent)")
 	fmt.Println("cDp:\t decrypt loaded content (silent, password mode)")
-	fmt.Println("xd:\t decrypt steg content")
+	fmt.Println("xd:\t decrypt steg content (hidden in the current layer)")
 	fmt.Println("xdp:\t decrypt steg content (password mode)")
 	fmt.Println("xD:\t decrypt steg content (silent)")
 	fmt.Println("xDp:\t decrypt steg content (silent, password mode)")
@@ -185,6 +223,11 @@ func helpInternal() {
 	fmt.Println("L:\t delete all empty lines")
 	fmt.Println("m:\t merge lines")
 	fmt.Println("s:\t split a line")
+	fmt.Println("rep:\t replace text in the whole content, or in certain line, or in lines range")
+	fmt.Println("rex:\t replace regexp (Go syntax, $1 in replacement refers to submatch)")
+	fmt.Println("\t options for rep/rex: p (password mode), s (secure input), c (confirm each line), e.g. 'rexpc 3 8'")
+	fmt.Println("u:\t undo the last editor operation")
+	fmt.Println("U:\t redo")
 	fmt.Println("p:\t print lines")
 	fmt.Println("P:\t print lines range")
 }
@@ -195,13 +238,21 @@ func help() {
 	fmt.Println("USAGE: xed [decrytpion_flags] [srcFile] [dstFile]")
 	fmt.Println("\td default decryption")
 	fmt.Println("\tp password mode")
+	fmt.Println("\tk combine password with keyfiles")
+	fmt.Println("\tz compress before encryption on save")
+	fmt.Println("\tl auto-lock after 5 minutes of inactivity")
+	fmt.Println("\tL auto-exit after 5 minutes of inactivity")
 	fmt.Println("\tD mute")
 	fmt.Println("\th help")
 }
 
 func info() {
-	pos := [2]string{"face", "steg"}
 	fmt.Printf("xed v.2.%d.102 \n", crutils.CipherVersion)
-	fmt.Printf("You are currently at %s \n", pos[cur])
-	fmt.Printf("face: %d lines, steg: %d lines \n", items[0].console.Len(), items[1].console.Len())
+	fmt.Printf("You are currently at %s \n", layerName(cur))
+	listLayers()
+	fmt.Printf("undo: %d, redo: %d \n", len(items[cur].undo), len(items[cur].redo))
+	fmt.Printf("keyfiles: %v \n", keyfiles)
+	fmt.Printf("compression: %v \n", compress)
+	fmt.Printf("backup: %v \n", common.Backup)
+	fmt.Printf("idle timeout: %v, exit on lock: %v \n", idleTimeout, lockExit)
 }
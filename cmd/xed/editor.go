@@ -28,9 +28,9 @@ func ChangeFrameStyle() {
 }
 
 func deriveConsoleFromSrc() {
-	primitives.Substitute(items[cur].src, '\r', newline)
+	primitives.Substitute(items[cur].src.Bytes(), '\r', newline)
 	items[cur].console = list.New()
-	s := items[cur].src
+	s := items[cur].src.Bytes()
 	beg := 0
 	for i := 0; i < len(s); i++ {
 		if s[i] == newline {
@@ -44,7 +44,7 @@ func deriveConsoleFromSrc() {
 }
 
 func cat() {
-	if len(items[cur].src) != 0 && items[cur].console.Len() == 0 {
+	if items[cur].src.Len() != 0 && items[cur].console.Len() == 0 {
 		deriveConsoleFromSrc()
 	}
 	displayContentAsText()
@@ -115,6 +115,7 @@ func LineAppend(cryptic bool) {
 	}
 
 	if s != nil {
+		defer commit(checkpoint())
 		items[cur].console.PushBack(s)
 		items[cur].changed = true
 		cat()
@@ -145,6 +146,7 @@ func insertLine(ln int, s []byte) bool {
 	i := 0
 	for x := items[cur].console.Front(); x != nil; x = x.Next() {
 		if i == ln {
+			defer commit(checkpoint())
 			items[cur].console.InsertBefore(s, x)
 			items[cur].changed = true
 			return true
@@ -184,26 +186,30 @@ func LineInsert(arg []string, cryptic bool) {
 
 func DeleteEmptyLines() {
 	var found bool
+	for x := items[cur].console.Front(); x != nil && !found; x = x.Next() {
+		found = len(x.Value.([]byte)) == 0
+	}
+	if !found {
+		fmt.Println("Empty lines not found")
+		return
+	}
+
+	defer commit(checkpoint())
 	i := items[cur].console.Front()
 	for i != nil {
 		p := i
 		i = i.Next()
 		if len(p.Value.([]byte)) == 0 {
 			deleteLine(cur, p)
-			found = true
 		}
 	}
-
-	if found {
-		cat()
-	} else {
-		fmt.Println("Empty lines not found")
-	}
+	cat()
 }
 
 func LinesDelete(arg []string) {
 	indexes := parseAndSortIntArgs(arg)
 	if indexes != nil {
+		defer commit(checkpoint())
 		primitives.ReverseInt(indexes)
 		for _, x := range indexes {
 			deleteLineAtIndex(x)
@@ -217,6 +223,7 @@ func LinesDeleteRange(arg []string) {
 	if len(indexes) == 2 {
 		beg := indexes[0]
 		end := indexes[1]
+		defer commit(checkpoint())
 		primitives.ReverseInt(indexes)
 		for i := end; i >= beg; i-- {
 			deleteLineAtIndex(i)
@@ -238,7 +245,7 @@ func deleteLineAtIndex(ln int) {
 }
 
 func LinesPrint(arg []string) {
-	if len(items[cur].src) != 0 && items[cur].console.Len() == 0 {
+	if items[cur].src.Len() != 0 && items[cur].console.Len() == 0 {
 		deriveConsoleFromSrc()
 	}
 
@@ -311,6 +318,7 @@ func mergeLines(ln int) bool {
 				return false
 			}
 
+			defer commit(checkpoint())
 			s1, _ := x.Value.([]byte)
 			s2, _ := y.Value.([]byte)
 			res := make([]byte, len(s1)+len(s2))
@@ -369,6 +377,7 @@ func splitLine(ln, pos int) bool {
 				return false
 			}
 
+			defer commit(checkpoint())
 			items[cur].console.InsertAfter(s[pos:], x)
 			items[cur].console.InsertAfter(s[:pos], x)
 			items[cur].console.Remove(x)
@@ -392,8 +401,9 @@ func cutLine(ln, pos int) bool {
 				return false
 			}
 
+			defer commit(checkpoint())
 			crutils.AnnihilateData(s[pos:])
-			s = s[:pos]
+			x.Value = s[:pos]
 			items[cur].changed = true
 			return true
 		}
@@ -451,6 +461,7 @@ func extendLine(ln int, ext []byte) bool {
 	i := 0
 	for x := items[cur].console.Front(); x != nil; x = x.Next() {
 		if i == ln {
+			defer commit(checkpoint())
 			prev, _ := x.Value.([]byte)
 			n := make([]byte, len(prev)+len(ext))
 			copy(n, prev)
@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bytes"
+	"container/list"
+	"encoding/binary"
+	"fmt"
+
+	"github.com/gluk256/crypto/crutils"
+)
+
+// Undo/redo history of the editor operations, separate for each layer.
+// Each snapshot is a copy of the console, serialized into the locked memory (see crutils.SecureBuffer),
+// so that the history does not leave the plaintext on the heap; the restored console is an ordinary copy.
+// Please note that each snapshot takes at least one locked page, and the locked memory is limited (RLIMIT_MEMLOCK);
+// beyond the limit the snapshots are still excluded from core dumps, but might be swapped.
+// The history is bounded, and the discarded snapshots are destroyed.
+
+const maxHistory = 32
+
+// layout: [line_size (4 bytes)][line]...
+func copyConsole(l *list.List) *crutils.SecureBuffer {
+	size := 0
+	for x := l.Front(); x != nil; x = x.Next() {
+		size += 4 + len(x.Value.([]byte))
+	}
+	res := crutils.NewSecureBuffer(size)
+	b := res.Bytes()
+	for x := l.Front(); x != nil; x = x.Next() {
+		s, _ := x.Value.([]byte)
+		binary.LittleEndian.PutUint32(b, uint32(len(s)))
+		copy(b[4:], s)
+		b = b[4+len(s):]
+	}
+	return res
+}
+
+// the lines point into the snapshot
+func snapshotLines(snapshot *crutils.SecureBuffer) [][]byte {
+	var res [][]byte
+	for b := snapshot.Bytes(); len(b) >= 4; {
+		sz := int(binary.LittleEndian.Uint32(b))
+		res = append(res, b[4:4+sz])
+		b = b[4+sz:]
+	}
+	return res
+}
+
+func pushSnapshot(stack []*crutils.SecureBuffer, snapshot *crutils.SecureBuffer) []*crutils.SecureBuffer {
+	if len(stack) >= maxHistory {
+		stack[0].Destroy()
+		stack[0] = nil
+		stack = stack[1:]
+	}
+	return append(stack, snapshot)
+}
+
+func popSnapshot(stack []*crutils.SecureBuffer) ([]*crutils.SecureBuffer, *crutils.SecureBuffer) {
+	last := len(stack) - 1
+	snapshot := stack[last]
+	stack[last] = nil
+	return stack[:last], snapshot
+}
+
+func clearStack(stack []*crutils.SecureBuffer) []*crutils.SecureBuffer {
+	for _, snapshot := range stack {
+		snapshot.Destroy()
+	}
+	return nil
+}
+
+func clearHistory(i int) {
+	items[i].undo = clearStack(items[i].undo)
+	items[i].redo = clearStack(items[i].redo)
+}
+
+func equalsConsole(snapshot *crutils.SecureBuffer, l *list.List) bool {
+	lines := snapshotLines(snapshot)
+	if len(lines) != l.Len() {
+		return false
+	}
+	i := 0
+	for x := l.Front(); x != nil; x = x.Next() {
+		if !bytes.Equal(lines[i], x.Value.([]byte)) {
+			return false
+		}
+		i++
+	}
+	return true
+}
+
+// must be called before every modification of the console, usually as: defer commit(checkpoint())
+func checkpoint() *crutils.SecureBuffer {
+	return copyConsole(items[cur].console)
+}
+
+// the snapshot is recorded only if the console was actually modified, otherwise it is discarded
+func commit(snapshot *crutils.SecureBuffer) {
+	if equalsConsole(snapshot, items[cur].console) {
+		snapshot.Destroy()
+		return
+	}
+	items[cur].undo = pushSnapshot(items[cur].undo, snapshot)
+	items[cur].redo = clearStack(items[cur].redo)
+}
+
+// the snapshot is destroyed
+func restoreConsole(snapshot *crutils.SecureBuffer) {
+	for x := items[cur].console.Front(); x != nil; x = items[cur].console.Front() {
+		deleteLine(cur, x)
+	}
+	for _, s := range snapshotLines(snapshot) {
+		c := make([]byte, len(s))
+		copy(c, s)
+		items[cur].console.PushBack(c)
+	}
+	snapshot.Destroy()
+	items[cur].changed = true
+}
+
+func Undo() {
+	if len(items[cur].undo) == 0 {
+		fmt.Println(">>> nothing to undo")
+		return
+	}
+	var snapshot *crutils.SecureBuffer
+	items[cur].redo = pushSnapshot(items[cur].redo, copyConsole(items[cur].console))
+	items[cur].undo, snapshot = popSnapshot(items[cur].undo)
+	restoreConsole(snapshot)
+	cat()
+}
+
+func Redo() {
+	if len(items[cur].redo) == 0 {
+		fmt.Println(">>> nothing to redo")
+		return
+	}
+	var snapshot *crutils.SecureBuffer
+	items[cur].undo = pushSnapshot(items[cur].undo, copyConsole(items[cur].console))
+	items[cur].redo, snapshot = popSnapshot(items[cur].redo)
+	restoreConsole(snapshot)
+	cat()
+}
@@ -0,0 +1,113 @@
+package main
+
+import (
+	"strings"
+	"testing"
+)
+
+func setConsole(lines ...string) {
+	initialize()
+	for _, s := range lines {
+		items[cur].console.PushBack([]byte(s))
+	}
+}
+
+func consoleText() string {
+	var lines []string
+	for x := items[cur].console.Front(); x != nil; x = x.Next() {
+		lines = append(lines, string(x.Value.([]byte)))
+	}
+	return strings.Join(lines, "\n")
+}
+
+func TestHistory(t *testing.T) {
+	cur = face
+	setConsole("one", "two", "three")
+	defer deleteAll()
+
+	// commands which do not change anything must not be recorded
+	LinesDelete([]string{"d", "3"})
+	LinesDeleteRange([]string{"dd", "3", "3"})
+	extendLine(0, nil)
+	if len(items[cur].undo) != 0 {
+		t.Fatalf("unchanged content recorded, history size %d", len(items[cur].undo))
+	}
+
+	LinesDelete([]string{"d", "1"})
+	if len(items[cur].undo) != 1 || consoleText() != "one\nthree" {
+		t.Fatalf("delete failed: [%s], history size %d", consoleText(), len(items[cur].undo))
+	}
+	mergeLines(0)
+	if len(items[cur].undo) != 2 || consoleText() != "onethree" {
+		t.Fatalf("merge failed: [%s]", consoleText())
+	}
+
+	Undo()
+	Undo()
+	if consoleText() != "one\ntwo\nthree" {
+		t.Fatalf("undo failed: [%s]", consoleText())
+	}
+	Undo()
+	if consoleText() != "one\ntwo\nthree" || len(items[cur].redo) != 2 {
+		t.Fatalf("wrong undo beyond history: [%s]", consoleText())
+	}
+	Redo()
+	if consoleText() != "one\nthree" {
+		t.Fatalf("redo failed: [%s]", consoleText())
+	}
+
+	// new modification clears the redo history, while the failed one does not
+	splitLine(0, 10)
+	if len(items[cur].redo) != 1 {
+		t.Fatal("redo history is cleared by failed command")
+	}
+	splitLine(0, 1)
+	if len(items[cur].redo) != 0 || consoleText() != "o\nne\nthree" {
+		t.Fatalf("split failed: [%s]", consoleText())
+	}
+}
+
+func TestHistoryLimit(t *testing.T) {
+	cur = face
+	setConsole("x")
+	defer deleteAll()
+
+	for i := 0; i < maxHistory*2; i++ {
+		extendLine(0, []byte("x"))
+	}
+	if len(items[cur].undo) != maxHistory {
+		t.Fatalf("wrong history size %d", len(items[cur].undo))
+	}
+	for i := 0; i < maxHistory; i++ {
+		Undo()
+	}
+	if len(consoleText()) != maxHistory+1 {
+		t.Fatalf("wrong content after undo: [%s]", consoleText())
+	}
+}
+
+func TestSnapshot(t *testing.T) {
+	cur = face
+	setConsole("", "a", "", "bc")
+	defer deleteAll()
+
+	snapshot := copyConsole(items[cur].console)
+	if !equalsConsole(snapshot, items[cur].console) {
+		t.Fatal("snapshot differs from the console")
+	}
+	restoreConsole(snapshot)
+	if consoleText() != "\na\n\nbc" {
+		t.Fatalf("wrong restored content: [%s]", consoleText())
+	}
+
+	setConsole()
+	snapshot = copyConsole(items[cur].console)
+	items[cur].console.PushBack([]byte{})
+	if equalsConsole(snapshot, items[cur].console) {
+		t.Fatal("empty snapshot equals the modified console")
+	}
+	restoreConsole(snapshot)
+	if items[cur].console.Len() != 0 {
+		t.Fatalf("wrong restored size %d", items[cur].console.Len())
+	}
+}
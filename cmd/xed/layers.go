@@ -0,0 +1,235 @@
+package main
+
+import (
+	"container/list"
+	"fmt"
+
+	"github.com/gluk256/crypto/crutils"
+)
+
+// Steganographic layers: the layer 0 is the face content, and each next layer is hidden
+// in the spacing of the previous one. The whole chain is encrypted starting from the innermost layer.
+// Each layer has its own key. The capacity of the layer is roughly a quarter of its padded size (see crutils.PlanSteg).
+
+func layerName(i int) string {
+	if i == face {
+		return "face"
+	}
+	return fmt.Sprintf("steg %d", i)
+}
+
+func addLayer() bool {
+	if len(items) >= MaxLayers {
+		fmt.Printf(">>> Error: the number of layers is limited to %d\n", MaxLayers)
+		return false
+	}
+	items = append(items, Content{console: list.New()})
+	return true
+}
+
+// the size of the layer content before encryption
+func layerSize(i int) int {
+	if sz := getConsoleSizeInBytes(i); sz > 0 {
+		return sz - 1 // without the last newline
+	}
+	return 0
+}
+
+// wipes and removes all the layers after i
+func deleteLayersAfter(i int) {
+	for j := len(items) - 1; j > i; j-- {
+		deleteContent(j)
+	}
+	items = items[:i+1]
+}
+
+// returns the index of the innermost non-empty layer
+func innermostLayer() int {
+	for i := len(items) - 1; i > face; i-- {
+		if items[i].console.Len() > 0 {
+			return i
+		}
+	}
+	return face
+}
+
+func listLayers() {
+	for i := range items {
+		mark := " "
+		if i == cur {
+			mark = "*"
+		}
+		sz := layerSize(i)
+		fmt.Printf("%s %-8s %d lines, %d bytes, capacity for the next layer: %d bytes\n",
+			mark, layerName(i), items[i].console.Len(), sz, crutils.StegCapacity(sz))
+	}
+}
+
+func switchLayer(args []string) {
+	if len(args) < 2 {
+		listLayers()
+		return
+	}
+	i, ok := a2i(args[1], 0, len(items)+1)
+	if !ok {
+		return
+	}
+	if i == len(items) && !addLayer() {
+		return
+	}
+	cur = i
+	cat()
+}
+
+func printStegFit(i int, f *crutils.StegFit) {
+	fmt.Printf("%s in %s: %d bytes, capacity %d bytes, headroom %d bytes, min size of %s: %d bytes",
+		layerName(i+1), layerName(i), f.Hidden, f.Capacity, f.Headroom, layerName(i), f.MinFace)
+	if f.Fits() {
+		fmt.Println()
+	} else {
+		fmt.Println(" >>> does not fit <<<")
+	}
+}
+
+func getLayerSizes() []int {
+	sizes := make([]int, innermostLayer()+1)
+	for i := range sizes {
+		sizes[i] = layerSize(i)
+	}
+	return sizes
+}
+
+// reports how the layers fit into each other, returns true if all of them fit
+func planLayers(sizes []int, verbose bool) bool {
+	res := true
+	plan := crutils.PlanSteg(sizes...)
+	for i := range plan {
+		if verbose || !plan[i].Fits() {
+			printStegFit(i, &plan[i])
+		}
+		res = res && plan[i].Fits()
+	}
+	return res
+}
+
+func PlanLayers() {
+	sizes := getLayerSizes()
+	if len(sizes) < 2 {
+		fmt.Printf("face: %d bytes, capacity for the hidden content: %d bytes\n", sizes[0], crutils.StegCapacity(sizes[0]))
+	} else if planLayers(sizes, true) {
+		fmt.Println("all the layers fit")
+	}
+}
+
+// encrypts the whole chain of layers (innermost first), and saves the result
+func FileSaveSteg(secureFace bool, secureSteg bool) {
+	last := innermostLayer()
+	if last == face {
+		fmt.Println(">>> Error: steganographic content does not exist")
+		return
+	}
+
+	content := make([][]byte, last+1)
+	defer func() {
+		for _, c := range content {
+			crutils.AnnihilateData(c)
+		}
+	}()
+	for i := range content {
+		if content[i] = content2raw(i, 0); content[i] == nil {
+			fmt.Printf(">>> Error: %s is empty\n", layerName(i))
+			return
+		}
+	}
+	sizes := make([]int, len(content))
+	for i := range content {
+		sizes[i] = len(content[i])
+	}
+	if !planLayers(sizes, false) {
+		return
+	}
+
+	var res []byte
+	for i := last; i >= face; i-- {
+		secure := secureSteg
+		if i == face {
+			secure = secureFace
+		}
+		fmt.Printf("%s encryption: ", layerName(i))
+		key, err := getKey(i, secure, true)
+		if err != nil {
+			fmt.Printf(">>> Error: %s\n", err.Error())
+			return
+		}
+		if len(key) == 0 {
+			fmt.Println(">>> Error: wrong key")
+			return
+		}
+
+		var encrypted []byte
+		if res == nil {
+			encrypted, err = crutils.Encrypt(key, content[i])
+		} else {
+			encrypted, err = crutils.EncryptSteg(key, content[i], res)
+		}
+		if err != nil {
+			fmt.Printf(">>> Error encrypting %s: %s\n", layerName(i), err)
+			return
+		}
+		res = encrypted
+	}
+
+	if saveData(res) {
+		keepEncrypted(res)
+		for i := range content {
+			items[i].changed = false
+		}
+	}
+	crutils.AnnihilateData(res)
+}
+
+// decrypts the content hidden in the current layer into the next one
+func stegDecrypt(secure bool, mute bool) bool {
+	if len(items[cur].pad) == 0 {
+		fmt.Println(">>> Error: current layer is not decrypted")
+		return false
+	}
+	next := cur + 1
+	if next == len(items) && !addLayer() {
+		return false
+	}
+
+	stegContent := make([]byte, len(items[cur].pad))
+	copy(stegContent, items[cur].pad)
+
+	key, err := getKey(next, secure, false)
+	if err != nil {
+		fmt.Printf(">>> Error: %s \n", err.Error())
+		return false
+	}
+	if len(key) == 0 {
+		fmt.Println(">>> Error: wrong key")
+		return false
+	}
+
+	b, ss2, err := crutils.DecryptStegContentOfUnknownSize(key, stegContent)
+	if err != nil {
+		fmt.Printf(">>> Error: %s\n", err)
+		return false
+	}
+
+	// the deeper layers were hidden in the previous content of the next layer
+	deleteLayersAfter(next)
+	k := items[next].key // the previous content of the layer is wiped, but the key is kept
+	items[next].key = nil
+	deleteContent(next)
+	items[next].key = k
+	setSrc(next, b)
+	items[next].pad = ss2
+	cur = next
+	deriveConsoleFromSrc()
+	if !mute {
+		cat()
+	}
+	return true
+}
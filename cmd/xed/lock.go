@@ -0,0 +1,112 @@
+package main
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/gluk256/crypto/terminal"
+)
+
+// Auto-lock: if no command is entered within the idle timeout, the screen is cleared and all the content is wiped.
+// Then xed either exits, or asks for the key to decrypt the file again (the encrypted data is kept in memory).
+// If nothing was loaded from a file, xed continues with the empty content.
+// The unsaved changes are lost (the explicit 'lock' command asks for confirmation first).
+// Only the command prompt is guarded by the timeout.
+
+const DefaultIdleTimeout = 5 * time.Minute
+
+var (
+	idleTimeout time.Duration // zero means disabled
+	lockExit    bool          // exit instead of asking for the key
+	requests    = make(chan struct{})
+	commands    = make(chan []byte)
+	pending     bool // the command is requested, but not yet received
+)
+
+func init() {
+	go readCommands()
+}
+
+func readCommands() {
+	for range requests {
+		commands <- terminal.PlainTextInput()
+	}
+}
+
+// returns false if the timeout expired (the input remains pending)
+func readCommand(timeout time.Duration) ([]byte, bool) {
+	if !pending {
+		requests <- struct{}{}
+		pending = true
+	}
+	var expired <-chan time.Time
+	if timeout > 0 {
+		t := time.NewTimer(timeout)
+		defer t.Stop()
+		expired = t.C
+	}
+	select {
+	case s := <-commands:
+		pending = false
+		return s, true
+	case <-expired:
+		return nil, false
+	}
+}
+
+func setIdleTimeout(args []string) {
+	if len(args) > 1 {
+		minutes, ok := a2i(args[1], 0, 24*60)
+		if !ok {
+			return
+		}
+		idleTimeout = time.Duration(minutes) * time.Minute
+	}
+	fmt.Printf("idle timeout: %v\n", idleTimeout)
+}
+
+// returns false if xed should exit
+func lock() bool {
+	enc := items[face].enc
+	depth := innermostLayer()
+	prev := cur
+	deleteAll()
+	cur = face
+	clear()
+	fmt.Println(">>> locked <<<")
+	if lockExit {
+		return false
+	} else if len(enc) == 0 {
+		fmt.Println(">>> the content was not loaded from a file, and can not be unlocked: all the content is wiped")
+		return true
+	}
+
+	for {
+		fmt.Print("Enter 'u' to unlock (password mode), 'U' to unlock (secure input), or 'q' to quit: ")
+		s, _ := readCommand(0)
+		cmd := string(s)
+		if cmd == "q" {
+			return false
+		} else if cmd != "u" && cmd != "U" {
+			continue
+		}
+		secure := cmd == "U"
+		b := make([]byte, len(enc))
+		copy(b, enc)
+		setSrc(face, b)
+		if !contentDecrypt(secure, true) {
+			deleteContent(face)
+			continue
+		}
+		for cur < depth {
+			if !stegDecrypt(secure, true) {
+				break
+			}
+		}
+		if cur > prev {
+			cur = prev
+		}
+		cat()
+		return true
+	}
+}
@@ -8,33 +8,37 @@ import (
 	"os"
 	"strings"
 
-	"github.com/gluk256/crypto/algo/primitives"
 	"github.com/gluk256/crypto/cmd/common"
 	"github.com/gluk256/crypto/crutils"
 	"github.com/gluk256/crypto/terminal"
 )
 
 const (
-	NumItems = 2
-	steg     = 1
-	face     = 0
+	face      = 0 // the outermost layer
+	MaxLayers = 16
 )
 
 type Content struct {
-	key     []byte
+	key     *crutils.SecureBuffer
 	pad     []byte
-	src     []byte     // the original data src, represents the file with encrpted/decrypted raw data
-	console *list.List // represents the visual output, originally derived from src
+	src     *crutils.SecureBuffer   // the original data src, represents the file with encrpted/decrypted raw data
+	console *list.List              // represents the visual output, originally derived from src
+	enc     []byte                  // encrypted data, kept for decryption after auto-lock
+	undo    []*crutils.SecureBuffer // snapshots of the console
+	redo    []*crutils.SecureBuffer
 	changed bool
 }
 
 var (
-	items [NumItems]Content
-	cur   int
+	items    []Content // layers, each next one is hidden in the spacing (pad) of the previous
+	cur      int
+	keyfiles bool // combine passwords with keyfiles
+	compress bool // compress before encryption (face content only)
 )
 
 func initialize() {
-	for i := 0; i < NumItems; i++ {
+	items = make([]Content, 2) // face and steg
+	for i := range items {
 		items[i].console = list.New()
 	}
 }
@@ -45,7 +49,7 @@ func cleanup() {
 }
 
 func deleteAll() {
-	for i := 0; i < NumItems; i++ {
+	for i := range items {
 		deleteContent(i)
 	}
 }
@@ -57,26 +61,42 @@ func deleteLine(i int, e *list.Element) {
 }
 
 func deleteContent(i int) {
-	crutils.AnnihilateData(items[cur].key)
-	crutils.AnnihilateData(items[cur].src)
-	crutils.AnnihilateData(items[cur].pad)
-
+	// the console lines might point into src, so they must be deleted before src is destroyed
 	if items[i].console != nil {
 		for x := items[i].console.Front(); x != nil; x = items[i].console.Front() {
 			deleteLine(i, x)
 		}
 	}
 
-	items[cur].src = nil
-	items[cur].key = nil
-	items[cur].pad = nil
-	items[cur].changed = false
+	clearHistory(i)
+	items[i].key.Destroy()
+	items[i].src.Destroy()
+	crutils.AnnihilateData(items[i].pad)
+
+	items[i].src = nil
+	items[i].key = nil
+	items[i].pad = nil
+	items[i].enc = nil
+	items[i].changed = false
 	items[i].console = list.New()
 }
 
+// the old src is destroyed, and the new data is moved to the locked memory
+func setSrc(i int, b []byte) {
+	clearHistory(i)
+	items[i].src.Destroy()
+	items[i].src = crutils.NewSecureBufferFrom(b)
+}
+
 func checkQuit() bool {
-	if items[cur].changed {
-		return common.Confirm("The file is not saved. Do you really want to quit and lose the changes?")
+	return checkUnsaved("quit")
+}
+
+func checkUnsaved(action string) bool {
+	for i := range items {
+		if items[i].changed {
+			return common.Confirm(fmt.Sprintf("The file is not saved. Do you really want to %s and lose the changes?", action))
+		}
 	}
 	return true
 }
@@ -101,10 +121,10 @@ func ls() {
 }
 
 func getKey(index int, cryptic bool, checkExisting bool) (res []byte, err error) {
-	if len(items[index].key) > 0 {
+	if items[index].key.Len() > 0 {
 		if checkExisting {
 			if common.Confirm("Do you want to use existing key?") {
-				return items[index].key, nil
+				return items[index].key.Bytes(), nil
 			}
 		}
 	}
@@ -113,11 +133,15 @@ func getKey(index int, cryptic bool, checkExisting bool) (res []byte, err error)
 	if cryptic {
 		flag = "s"
 	}
+	if keyfiles {
+		flag += "k"
+	}
 
 	res, err = common.GetPassword(flag)
 	if err == nil {
-		crutils.AnnihilateData(items[index].key)
-		items[index].key = res
+		items[index].key.Destroy()
+		items[index].key = crutils.NewSecureBufferFrom(res)
+		res = items[index].key.Bytes()
 	}
 	return res, err
 }
@@ -175,11 +199,18 @@ func main() {
 	run()
 }
 
+// the flags are applied even if the file is not loaded (e.g. auto-lock must not be silently disabled)
 func LoadAndDecrypt() {
+	flags := os.Args[1]
+	secure := !strings.Contains(flags, "p")
+	mute := strings.Contains(flags, "m")
+	keyfiles = strings.Contains(flags, "k")
+	compress = strings.Contains(flags, "z")
+	if strings.Contains(flags, "l") || strings.Contains(flags, "L") {
+		idleTimeout = DefaultIdleTimeout
+		lockExit = strings.Contains(flags, "L")
+	}
 	if FileLoad(os.Args[1:], false) {
-		flags := os.Args[1]
-		secure := !strings.Contains(flags, "p")
-		mute := strings.Contains(flags, "m")
 		contentDecrypt(secure, mute)
 	}
 }
@@ -188,13 +219,21 @@ func run() {
 	var prev string
 	for {
 		fmt.Print("Enter command: ")
-		s := terminal.PlainTextInput()
-		if s != nil {
+		s, ok := readCommand(idleTimeout)
+		if !ok {
+			if !lock() {
+				return
+			}
+		} else if s != nil {
 			cmd := string(s)
 			if cmd == "q" {
 				if checkQuit() {
 					return
 				}
+			} else if cmd == "lock" {
+				if checkUnsaved("lock") && !lock() {
+					return
+				}
 			} else {
 				prev = processCommand(cmd, prev)
 			}
@@ -222,7 +261,7 @@ func FileLoad(args []string, show bool) bool {
 		return false
 	}
 
-	items[cur].src = b
+	setSrc(cur, b)
 	if show {
 		deriveConsoleFromSrc()
 		cat()
@@ -230,24 +269,34 @@ func FileLoad(args []string, show bool) bool {
 	return true
 }
 
-func saveData(data []byte) {
+func saveData(data []byte) bool {
 	if len(data) == 0 {
 		fmt.Println(">>> Error: content is not found")
-		return
+		return false
 	}
 
 	filename := getFileName()
 	if len(filename) == 0 {
 		fmt.Println(">>> Error: filename is empty")
-		return
+		return false
 	}
 
-	err := ioutil.WriteFile(filename, data, 0666)
+	if !common.ConfirmOverwrite(filename) {
+		return false
+	}
+	err := common.WriteFileAtomic(filename, data)
 	if err != nil {
 		fmt.Printf(">>> Error: %s \n", err)
-	} else {
-		items[cur].changed = false
+		return false
 	}
+	items[cur].changed = false
+	return true
+}
+
+// the saved file replaces the encrypted data, which is decrypted after auto-lock
+func keepEncrypted(data []byte) {
+	items[face].enc = make([]byte, len(data))
+	copy(items[face].enc, data)
 }
 
 // func FileSavePlainText(arg []string) {
@@ -269,77 +318,16 @@ func FileSave(secure bool) {
 
 	x, err := encryptData(secure, b)
 	if err == nil {
-		saveData(x)
+		if saveData(x) && cur == face {
+			keepEncrypted(x)
+		}
 		crutils.AnnihilateData(x)
 		crutils.AnnihilateData(b)
 	}
 }
 
-func FileSaveSteg(secureFace bool, secureSteg bool) {
-	if steg < 0 || items[steg].console.Len() == 0 {
-		fmt.Println(">>> Error: steganographic content does not exist")
-		return
-	}
-
-	faceContent := content2raw(face, 0)
-	stegContent := content2raw(steg, len(faceContent)*4)
-	defer crutils.AnnihilateData(faceContent)
-	defer crutils.AnnihilateData(stegContent)
-	if faceContent == nil {
-		return
-	}
-
-	encrypedStegSize := len(stegContent) + crutils.EncryptedSizeDiff
-	allowedStegSize := primitives.FindNextPowerOfTwo(len(faceContent))
-	if encrypedStegSize > allowedStegSize {
-		fmt.Printf(">>> Error: plain text is too small in comparison with steganographic content ")
-		fmt.Printf("[%d vs. %d] \n", len(faceContent), len(stegContent))
-		return
-	}
-
-	fmt.Print("steganographic content encryption: ")
-	keySteg, err := getKey(steg, secureSteg, true)
-	if err != nil {
-		fmt.Printf(">>> Error:%s", err.Error())
-		return
-	}
-	if len(keySteg) == 0 {
-		fmt.Println(">>> Error: wrong key")
-		return
-	}
-
-	fmt.Print("face content encryption: ")
-	keyFace, err := getKey(face, secureFace, true)
-	if err != nil {
-		fmt.Printf(">>> Error:%s", err.Error())
-		return
-	}
-	if len(keyFace) == 0 {
-		crutils.AnnihilateData(keySteg)
-		fmt.Println(">>> Error: wrong key")
-		return
-	}
-
-	encryptedSteg, err := crutils.Encrypt(keySteg, stegContent)
-	if err != nil {
-		fmt.Printf(">>> Error encrypting steg: %s\n", err)
-		return
-	}
-
-	res, err := crutils.EncryptSteg(keyFace, faceContent, encryptedSteg)
-	if err != nil {
-		fmt.Printf(">>> Error encrypting cur: %s\n", err)
-		return
-	}
-
-	if res != nil {
-		saveData(res)
-		crutils.AnnihilateData(res)
-	}
-}
-
 func encryptData(secure bool, d []byte) ([]byte, error) {
-	key, err := getKey(face, secure, true)
+	key, err := getKey(cur, secure, true)
 	if err != nil {
 		fmt.Printf(">>> Error: %s \n", err.Error())
 		return nil, err
@@ -348,7 +336,14 @@ func encryptData(secure bool, d []byte) ([]byte, error) {
 		fmt.Println(">>> Error: empty key")
 		return nil, errors.New("empty key")
 	}
-	res, err := crutils.Encrypt(key, d)
+	var res []byte
+	if compress {
+		h := crutils.NewHeader(crutils.ModeMain)
+		h.Flags |= crutils.FlagCompressed
+		res, err = crutils.EncryptWithHeader(h, key, d, nil)
+	} else {
+		res, err = crutils.Encrypt(key, d)
+	}
 	if err != nil {
 		fmt.Printf(">>> Error: %s\n", err)
 	}
@@ -356,10 +351,10 @@ func encryptData(secure bool, d []byte) ([]byte, error) {
 }
 
 func contentDecrypt(secure bool, mute bool) bool {
-	content := make([]byte, len(items[cur].src))
-	copy(content, items[cur].src)
+	content := make([]byte, items[cur].src.Len())
+	copy(content, items[cur].src.Bytes())
 
-	key, err := getKey(face, secure, true)
+	key, err := getKey(cur, secure, true)
 	if err != nil {
 		fmt.Printf(">>> Error: %s \n", err.Error())
 		return false
@@ -369,45 +364,21 @@ func contentDecrypt(secure bool, mute bool) bool {
 		return false
 	}
 
+	enc := make([]byte, items[cur].src.Len())
+	copy(enc, items[cur].src.Bytes())
 	b, s, err := crutils.Decrypt(key, content)
 	if err != nil {
 		fmt.Printf(">>> Error: %s\n", err)
 		return false
 	}
-
-	items[cur].src = b
-	items[cur].pad = s
-	deriveConsoleFromSrc()
-	if !mute {
-		cat()
-	}
-	return true
-}
-
-func stegDecrypt(secure bool, mute bool) bool {
-	stegContent := make([]byte, len(items[face].pad))
-	copy(stegContent, items[face].pad)
-
-	key, err := getKey(steg, secure, false)
-	if err != nil {
-		fmt.Printf(">>> Error: %s \n", err.Error())
-		return false
-	}
-	if len(key) == 0 {
-		fmt.Println(">>> Error: wrong key")
-		return false
-	}
-
-	b, ss2, err := crutils.DecryptStegContentOfUnknownSize(key, stegContent)
-	if err != nil {
-		fmt.Printf(">>> Error: %s\n", err)
-		return false
+	if h, err := crutils.ParseHeader(items[cur].src.Bytes()); err == nil && h.IsCompressed() && !compress {
+		compress = true // keep the file compressed
+		fmt.Println("compression: true")
 	}
 
-	items[steg].src = b
-	items[steg].pad = ss2
-	items[steg].key = key
-	cur = steg
+	setSrc(cur, b)
+	items[cur].pad = s
+	items[cur].enc = enc
 	deriveConsoleFromSrc()
 	if !mute {
 		cat()
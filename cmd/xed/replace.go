@@ -0,0 +1,142 @@
+package main
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"github.com/gluk256/crypto/cmd/common"
+	"github.com/gluk256/crypto/crutils"
+	"github.com/gluk256/crypto/terminal"
+)
+
+// Search and replace: "rep" (literal) or "rex" (regular expression, Go syntax; $1 in replacement refers to submatch),
+// followed by options: 'p' (password mode input), 's' (secure input), 'c' (confirm each line).
+// Optional args: line number, or range of lines; by default the whole content is processed.
+// In password/secure mode neither the pattern nor the modified lines are displayed.
+// Please note that the compiled regexp keeps its own copy of the pattern, which can not be annihilated.
+
+const replaceOptions = "psc"
+
+func isReplaceCommand(cmd string) bool {
+	if !strings.HasPrefix(cmd, "rep") && !strings.HasPrefix(cmd, "rex") {
+		return false
+	}
+	return len(strings.Trim(cmd[3:], replaceOptions)) == 0
+}
+
+func readReplaceInput(prompt string, cryptic bool, scramble bool) []byte {
+	fmt.Print(prompt)
+	if !cryptic {
+		return terminal.PlainTextInput()
+	} else if scramble {
+		return terminal.SecureInput(false)
+	} else {
+		return terminal.PasswordModeInput()
+	}
+}
+
+func getReplaceRange(args []string) (beg int, end int, ok bool) {
+	if len(args) < 2 {
+		return 0, items[cur].console.Len() - 1, true
+	}
+	indexes := parseAndSortIntArgs(args)
+	if len(indexes) == 1 {
+		return indexes[0], indexes[0], true
+	} else if len(indexes) == 2 {
+		return indexes[0], indexes[1], true
+	}
+	if len(indexes) > 2 {
+		fmt.Println(">>> Error: line number or range expected")
+	}
+	return 0, 0, false
+}
+
+func confirmReplace(ln int, prev []byte, next []byte, cryptic bool) bool {
+	if !cryptic {
+		fmt.Printf("%03d│ %s\n", ln, prev)
+		fmt.Printf("%03d│ %s\n", ln, next)
+	}
+	return common.Confirm(fmt.Sprintf("Replace in line %d?", ln))
+}
+
+func Replace(args []string) {
+	opt := args[0][3:]
+	regex := strings.HasPrefix(args[0], "rex")
+	cryptic := strings.ContainsAny(opt, "ps")
+	scramble := strings.Contains(opt, "s")
+	confirmEach := strings.Contains(opt, "c")
+
+	if items[cur].console.Len() == 0 {
+		fmt.Println(">>> Error: content is empty")
+		return
+	}
+	beg, end, ok := getReplaceRange(args)
+	if !ok {
+		return
+	}
+
+	pattern := readReplaceInput("Enter pattern: ", cryptic, scramble)
+	defer crutils.AnnihilateData(pattern)
+	if len(pattern) == 0 {
+		fmt.Println(">>> Error: empty pattern")
+		return
+	}
+	replacement := readReplaceInput("Enter replacement: ", cryptic, scramble)
+	defer crutils.AnnihilateData(replacement)
+	if replacement == nil {
+		return
+	}
+
+	var re *regexp.Regexp
+	if regex {
+		var err error
+		if re, err = regexp.Compile(string(pattern)); err != nil {
+			if cryptic {
+				fmt.Println(">>> Error: invalid regexp")
+			} else {
+				fmt.Printf(">>> Error: %s\n", err)
+			}
+			return
+		}
+	}
+
+	changed := 0
+	ln := 0
+	for x := items[cur].console.Front(); x != nil && ln <= end; x, ln = x.Next(), ln+1 {
+		if ln < beg {
+			continue
+		}
+		s, _ := x.Value.([]byte)
+		var res []byte
+		if regex && re.Match(s) {
+			res = re.ReplaceAll(s, replacement)
+		} else if !regex && bytes.Contains(s, pattern) {
+			res = bytes.ReplaceAll(s, pattern, replacement)
+		} else {
+			continue
+		}
+
+		if bytes.Equal(res, s) || (confirmEach && !confirmReplace(ln, s, res, cryptic)) {
+			crutils.AnnihilateData(res)
+			continue
+		}
+		if changed == 0 {
+			defer commit(checkpoint())
+		}
+		x.Value = res
+		crutils.AnnihilateData(s)
+		items[cur].changed = true
+		changed++
+	}
+
+	if changed == 0 {
+		fmt.Println(">>> not found <<<")
+	} else if cryptic {
+		fmt.Printf("%d lines changed\n", changed)
+	} else {
+		cat()
+		fmt.Printf("%d lines changed\n", changed)
+	}
+}
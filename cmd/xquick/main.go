@@ -1,8 +1,9 @@
 package main
 
 import (
+	"errors"
 	"fmt"
-	"io/ioutil"
+	"io"
 	"os"
 	"strings"
 
@@ -10,70 +11,192 @@ import (
 	"github.com/gluk256/crypto/crutils"
 )
 
+var errWrongFlags = errors.New("wrong flags")
+
 func help() {
 	fmt.Printf("xquick v.0.%d.1 \n", crutils.CipherVersion)
 	fmt.Println("encrypt/decrypt a [big] file with stream cipher (xor only)")
-	fmt.Println("USAGE: xquick flags srcFile dstFile")
+	fmt.Println("USAGE: xquick flags srcFile dstFile ('-' means stdin/stdout)")
 	fmt.Println("\t -e encrypt (default mode)")
 	fmt.Println("\t -d decrypt")
 	fmt.Println("\t -r random password")
+	fmt.Println("\t -l legacy format (the whole file is processed in memory, header is optional)")
+	fmt.Println("\t -v add versioned header (always added in chunked format)")
+	fmt.Println("\t -a use memory-hard KDF argon2id (implies -v)")
+	fmt.Println("\t -y use memory-hard KDF scrypt (implies -v)")
 	fmt.Println("\t -s secure password input")
 	fmt.Println("\t -x extra secure password input")
+	fmt.Println("\t -k combine password with keyfiles (certificate, arbitrary files, hex secret)")
+	fmt.Println("\t -b batch mode (non-interactive)")
+	fmt.Println("\t -o overwrite existing dstFile without confirmation")
+	fmt.Println("\t -B keep the overwritten dstFile as numbered backup (dstFile.~N~)")
 	fmt.Println("\t -h help")
+	common.PrintBatchHelp()
+	fmt.Println("exit code is 1 on failure")
 }
 
-func processCommandArgs() (flags string, srcFile string, dstFile string) {
+// returns empty flags if there is nothing to do (e.g. help), or error if the args are wrong
+func processCommandArgs() (flags string, srcFile string, dstFile string, err error) {
 	var zero string
 	if len(os.Args) > 1 {
 		flags = os.Args[1]
 		if strings.Contains(flags, "h") || strings.Contains(flags, "?") {
 			help()
-			return zero, zero, zero
+			return zero, zero, zero, nil
 		}
 	}
 
 	if len(os.Args) != 4 {
-		fmt.Println("ERROR: wrong number of parameters.")
-		return zero, zero, zero
+		return zero, zero, zero, errors.New("wrong number of parameters")
 	}
 
 	if strings.Contains(flags, "r") {
 		if strings.Contains(flags, "d") {
 			fmt.Println("Random password ('r') is incompatible with decryption ('d').")
-			fmt.Println("ERROR: wrong flags.")
-			return zero, zero, zero
+			return zero, zero, zero, errWrongFlags
 		} else if !strings.Contains(flags, "e") {
 			flags += "e"
 		}
 	}
 
 	if !strings.Contains(flags, "e") && !strings.Contains(flags, "d") {
-		fmt.Println("ERROR: neither encryption nor decryption specified.")
-		return zero, zero, zero
+		return zero, zero, zero, errors.New("neither encryption nor decryption specified")
+	}
+
+	common.AllowOverwrite = strings.Contains(flags, "o")
+	common.Backup = strings.Contains(flags, "B")
+
+	if strings.Contains(flags, "b") {
+		if strings.ContainsAny(flags, "rsx") {
+			fmt.Println("Batch mode ('b') is incompatible with interactive password input ('r', 's', 'x').")
+			return zero, zero, zero, errWrongFlags
+		}
+		common.Batch = true
 	}
 
-	return flags, os.Args[2], os.Args[3]
+	return flags, os.Args[2], os.Args[3], nil
 }
 
 func main() {
-	flags, srcFile, dstFile := processCommandArgs()
+	flags, srcFile, dstFile, err := processCommandArgs()
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err.Error())
+		common.Exit(err)
+	}
 	if len(flags) != 0 {
-		defer crutils.ProveDataDestruction()
-		run(flags, srcFile, dstFile)
+		err = common.SetupStdStreams(srcFile, dstFile)
+		if err != nil {
+			fmt.Printf("ERROR: %s\n", err.Error())
+		} else {
+			err = run(flags, srcFile, dstFile)
+		}
+		crutils.ProveDataDestruction()
+		common.Exit(err)
+	}
+}
+
+func run(flags string, srcFile string, dstFile string) error {
+	if strings.Contains(flags, "e") && strings.Contains(flags, "l") {
+		return runInMemory(flags, srcFile, dstFile)
+	} else if strings.Contains(flags, "d") && !isChunkedFile(srcFile) {
+		return runInMemory(flags, srcFile, dstFile)
+	}
+	return runStream(flags, srcFile, dstFile)
+}
+
+func isChunkedFile(filename string) bool {
+	if filename == common.StdStream {
+		hdr, err := common.Stdin.Peek(crutils.HeaderSize)
+		return err == nil && crutils.IsQuickChunked(hdr)
+	}
+	f, err := os.Open(filename)
+	if err != nil {
+		return false
+	}
+	defer f.Close()
+	hdr := make([]byte, crutils.HeaderSize)
+	_, err = io.ReadFull(f, hdr)
+	return err == nil && crutils.IsQuickChunked(hdr)
+}
+
+func isSameFile(a string, b string) bool {
+	sa, err := os.Stat(a)
+	if err != nil {
+		return false
+	}
+	sb, err := os.Stat(b)
+	return err == nil && os.SameFile(sa, sb)
+}
+
+// the file is processed chunk by chunk in parallel, without loading it into memory
+func runStream(flags string, srcFile string, dstFile string) error {
+	if isSameFile(srcFile, dstFile) {
+		fmt.Println("ERROR: srcFile and dstFile must be different")
+		return errors.New("same file")
+	}
+	if !common.ConfirmOverwrite(dstFile) {
+		return errors.New("dstFile already exists")
+	}
+	var src io.Reader = common.Stdin
+	if srcFile != common.StdStream {
+		f, err := os.Open(srcFile)
+		if err != nil {
+			fmt.Printf("Failed to load data: %s\n", err.Error())
+			return err
+		}
+		defer f.Close()
+		src = f
+	}
+
+	key, err := common.GetPassword(flags)
+	defer crutils.AnnihilateData(key)
+
+	dst := common.Stdout
+	if err == nil && dstFile != common.StdStream {
+		dst, err = common.CreateTemp(dstFile)
 	}
+	if err == nil {
+		if strings.Contains(flags, "e") {
+			h := common.GetHeader(flags, crutils.ModeQuick)
+			if h == nil {
+				h = crutils.NewQuickChunkedHeader()
+			} else {
+				h.ChunkSize = crutils.QuickChunkSize
+			}
+			err = crutils.EncryptQuickStream(h, key, src, dst)
+		} else {
+			err = crutils.DecryptQuickStream(key, src, dst)
+		}
+		if dstFile != common.StdStream {
+			if err == nil {
+				err = common.CommitTemp(dst, dstFile)
+			} else {
+				common.DiscardTemp(dst) // incomplete result must not remain
+			}
+		}
+	}
+
+	crutils.AnnihilateData(key)
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err.Error())
+	}
+	return err
 }
 
-func run(flags string, srcFile string, dstFile string) {
+func runInMemory(flags string, srcFile string, dstFile string) error {
 	data := loadDataFromFile(flags, srcFile)
 	if len(data) == 0 {
-		return
+		return errors.New("no data")
 	}
 
 	key, err := common.GetPassword(flags)
 	defer crutils.AnnihilateData(key)
 
 	if err == nil {
-		if strings.Contains(flags, "e") {
+		h := common.GetHeader(flags, crutils.ModeQuick)
+		if strings.Contains(flags, "e") && h != nil {
+			data, err = crutils.EncryptWithHeader(h, key, data, nil)
+		} else if strings.Contains(flags, "e") {
 			data, err = crutils.EncryptQuick(key, data)
 		} else {
 			data, err = crutils.DecryptQuick(key, data)
@@ -82,14 +205,14 @@ func run(flags string, srcFile string, dstFile string) {
 
 	crutils.AnnihilateData(key)
 	if err == nil {
-		common.SaveData(dstFile, data)
-	} else {
-		fmt.Printf("ERROR: %s\n", err.Error())
+		return common.SaveData(dstFile, data)
 	}
+	fmt.Printf("ERROR: %s\n", err.Error())
+	return err
 }
 
 func loadDataFromFile(flags string, filename string) []byte {
-	data, err := ioutil.ReadFile(filename)
+	data, err := common.LoadData(filename)
 	if err != nil {
 		fmt.Printf("Failed to load data: %s\n", err.Error())
 		return nil
@@ -0,0 +1,217 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/gluk256/crypto/cmd/common"
+	"github.com/gluk256/crypto/crutils"
+	"github.com/gluk256/crypto/shamir"
+	"github.com/gluk256/crypto/terminal"
+)
+
+const (
+	MaxSecretSize = 64 * 1024 // shares are supposed to be printed or stored separately
+	ShareExt      = ".share"
+	ArmorShare    = "XSHAMIR SHARE"
+)
+
+func help() {
+	fmt.Println("xshamir v.1.0")
+	fmt.Println("split the secret into N shares, any K of which are enough to recover it (Shamir secret sharing)")
+	fmt.Println("USAGE: xshamir flags [srcFile] [shareFiles...]")
+	fmt.Println("\t -h help")
+	fmt.Println("\t -c combine the shares (otherwise split)")
+	fmt.Println("\t -t enter the secret as text instead of srcFile")
+	fmt.Println("\t -p password mode text input")
+	fmt.Println("\t -s secure text input")
+	fmt.Println("\t -f save the shares to files (srcFile.share.N), or save the combined secret to file")
+	fmt.Println("\t -a text-armored shares (otherwise hex)")
+	fmt.Println("in combine mode all the args are share files; if absent, the shares are entered manually")
+}
+
+func main() {
+	if len(os.Args) == 1 {
+		help()
+		return
+	}
+	flags := os.Args[1]
+	if strings.Contains(flags, "h") || strings.Contains(flags, "?") {
+		help()
+		return
+	}
+
+	defer crutils.ProveDataDestruction()
+	if strings.Contains(flags, "c") {
+		combine(flags, os.Args[2:])
+	} else {
+		var srcFile string
+		if len(os.Args) > 2 {
+			srcFile = os.Args[2]
+		}
+		split(flags, srcFile)
+	}
+}
+
+func getSecret(flags string, srcFile string) ([]byte, error) {
+	if strings.Contains(flags, "t") || strings.Contains(flags, "p") || strings.Contains(flags, "s") {
+		return common.GetText(flags, "the secret"), nil
+	}
+	if len(srcFile) == 0 {
+		srcFile = common.GetFileName()
+	}
+	return ioutil.ReadFile(srcFile)
+}
+
+func getParams() (n int, k int, err error) {
+	var x uint32
+	if x, err = common.GetUint("the number of shares"); err != nil {
+		return 0, 0, err
+	}
+	n = int(x)
+	if x, err = common.GetUint("the threshold (min number of shares to recover the secret)"); err != nil {
+		return 0, 0, err
+	}
+	return n, int(x), nil
+}
+
+func split(flags string, srcFile string) {
+	secret, err := getSecret(flags, srcFile)
+	defer crutils.AnnihilateData(secret)
+	if err != nil {
+		fmt.Printf("Failed to load the secret: %s\n", err.Error())
+		return
+	}
+	if len(secret) == 0 {
+		fmt.Println("Error: empty secret")
+		return
+	}
+	if len(secret) > MaxSecretSize {
+		fmt.Printf("Error: the secret is too big [%d bytes], max size is %d\n", len(secret), MaxSecretSize)
+		return
+	}
+
+	n, k, err := getParams()
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	shares, err := shamir.Split(secret, n, k)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+
+	armored := strings.Contains(flags, "a")
+	if strings.Contains(flags, "f") {
+		if len(srcFile) == 0 || strings.Contains(flags, "t") {
+			fmt.Print("base name for the shares, ")
+			srcFile = common.GetFileName()
+		}
+		for i, s := range shares {
+			name := fmt.Sprintf("%s%s.%d", srcFile, ShareExt, i+1)
+			if common.SaveData(name, encodeShare(s, armored)) != nil {
+				return
+			}
+			fmt.Printf("share %d saved: %s\n", i+1, name)
+		}
+	} else {
+		for i, s := range shares {
+			fmt.Printf("share %d:\n%s", i+1, encodeShare(s, armored))
+		}
+	}
+	fmt.Printf("%d of %d shares are required to recover the secret\n", k, n)
+}
+
+func encodeShare(share []byte, armored bool) []byte {
+	if !armored {
+		return []byte(hex.EncodeToString(share) + "\n")
+	}
+	return common.Armor(ArmorShare, share, fmt.Sprintf("Share: %d", share[0]), fmt.Sprintf("Threshold: %d", share[1]))
+}
+
+func decodeShare(raw []byte) ([]byte, error) {
+	if !common.IsArmored(raw) {
+		return hex.DecodeString(strings.TrimSpace(string(raw)))
+	}
+	kind, _, res, err := common.Dearmor(raw)
+	if err == nil && kind != ArmorShare {
+		err = fmt.Errorf("unexpected armored content [%s]", kind)
+	}
+	return res, err
+}
+
+func loadShares(files []string) (shares [][]byte, err error) {
+	for _, f := range files {
+		raw, err := ioutil.ReadFile(f)
+		if err != nil {
+			return shares, err
+		}
+		s, err := decodeShare(raw)
+		if err == nil {
+			err = shamir.VerifyShare(s)
+		}
+		if err != nil {
+			return shares, fmt.Errorf("wrong share [%s]: %s", f, err.Error())
+		}
+		shares = append(shares, s)
+	}
+	return shares, nil
+}
+
+func enterShares() (shares [][]byte) {
+	for i := 1; ; i++ {
+		fmt.Printf("please enter share #%d (hex or armored, empty to finish): ", i)
+		raw := common.CompleteArmoredInput(terminal.PlainTextInput())
+		if len(raw) == 0 {
+			return shares
+		}
+		s, err := decodeShare(raw)
+		if err == nil {
+			err = shamir.VerifyShare(s)
+		}
+		if err != nil {
+			fmt.Printf("Error: %s, please try again\n", err.Error())
+			i--
+			continue
+		}
+		shares = append(shares, s)
+	}
+}
+
+func combine(flags string, files []string) {
+	var shares [][]byte
+	var err error
+	if len(files) > 0 {
+		shares, err = loadShares(files)
+	} else {
+		shares = enterShares()
+	}
+	defer func() {
+		for _, s := range shares {
+			crutils.AnnihilateData(s)
+		}
+	}()
+	if err != nil {
+		fmt.Printf("Failed to load shares: %s\n", err.Error())
+		return
+	}
+
+	secret, err := shamir.Combine(shares)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	defer crutils.AnnihilateData(secret)
+
+	if strings.Contains(flags, "f") {
+		common.SaveData("", secret)
+	} else if common.IsAscii(secret) {
+		fmt.Printf("secret: %s\n", secret)
+	} else {
+		fmt.Printf("secret: %x\n", secret)
+	}
+}
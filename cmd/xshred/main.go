@@ -0,0 +1,106 @@
+package main
+
+import (
+	"fmt"
+	"os"
+	"strings"
+
+	"github.com/gluk256/crypto/cmd/common"
+	"github.com/gluk256/crypto/crutils"
+)
+
+func help() {
+	fmt.Println("xshred v.1.0")
+	fmt.Println("destroy files: overwrite with random data, rename, truncate and remove")
+	fmt.Println("USAGE: xshred flags file [file...]")
+	fmt.Println("\t -h help")
+	fmt.Println("\t -r recursive (shred directories)")
+	fmt.Println("\t -n dry run (only list the files to be shredded)")
+	fmt.Println("\t -p set the number of passes (default is 3)")
+	fmt.Println("\t -f force (don't ask for confirmation)")
+	fmt.Println("Please note that on SSD, journaling and copy-on-write file systems the old data might survive anyway.")
+}
+
+func main() {
+	if len(os.Args) < 3 {
+		help()
+		return
+	}
+	flags := os.Args[1]
+	if strings.Contains(flags, "h") || strings.Contains(flags, "?") {
+		help()
+		return
+	}
+
+	files, dirs, err := collect(flags, os.Args[2:])
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		os.Exit(1)
+	}
+	for _, f := range files {
+		fmt.Println(f)
+	}
+	fmt.Printf("%d files, %d directories\n", len(files), len(dirs))
+	if strings.Contains(flags, "n") || len(files)+len(dirs) == 0 {
+		return
+	}
+
+	passes := crutils.DefaultShredPasses
+	if strings.Contains(flags, "p") {
+		p, err := common.GetUint("the number of passes")
+		if err != nil || p == 0 {
+			fmt.Println("Error: wrong number of passes")
+			os.Exit(1)
+		}
+		passes = int(p)
+	}
+	if !strings.Contains(flags, "f") && !common.Confirm("Do you really want to destroy these files?") {
+		return
+	}
+
+	if err = shred(os.Args[2:], passes); err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		os.Exit(1)
+	}
+	fmt.Println("Done")
+}
+
+func collect(flags string, names []string) (files []string, dirs []string, err error) {
+	for _, name := range names {
+		info, err := os.Lstat(name)
+		if err != nil {
+			return nil, nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, name)
+		} else if !strings.Contains(flags, "r") {
+			return nil, nil, fmt.Errorf("[%s] is a directory, recursive mode ('r') is required", name)
+		} else {
+			f, d, err := crutils.ListTree(name)
+			if err != nil {
+				return nil, nil, err
+			}
+			files = append(files, f...)
+			dirs = append(dirs, d...)
+		}
+	}
+	return files, dirs, nil
+}
+
+func shred(names []string, passes int) error {
+	for _, name := range names {
+		info, err := os.Lstat(name)
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			err = crutils.ShredDir(name, passes)
+		} else {
+			err = crutils.ShredFile(name, passes)
+		}
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
@@ -0,0 +1,164 @@
+package main
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/gluk256/crypto/cmd/common"
+	"github.com/gluk256/crypto/crutils"
+	"github.com/gluk256/crypto/terminal"
+)
+
+const SignatureExt = ".sig"
+
+func help() {
+	fmt.Println("xsign v.1.0")
+	fmt.Println("sign a file or verify the detached signature (Ed25519)")
+	fmt.Println("USAGE: xsign flags [srcFile] [sigFile]")
+	fmt.Println("\t -h help")
+	fmt.Println("\t -g generate new signing key pair (srcFile is the key name)")
+	fmt.Println("\t -v verify signature (otherwise sign)")
+	fmt.Println("\t -f save signature to file (default sigFile is srcFile.sig)")
+	fmt.Println("\t -t enter text instead of srcFile (e.g. hash printed by xash)")
+	fmt.Println("\t -s secure password input")
+	fmt.Println("\t -x extra secure password input")
+}
+
+func processCommandArgs() (flags string, srcFile string, sigFile string) {
+	if len(os.Args) == 1 {
+		flags = "h"
+	}
+	if len(os.Args) > 1 {
+		flags = os.Args[1]
+	}
+	if len(os.Args) > 2 {
+		srcFile = os.Args[2]
+	}
+	if len(os.Args) > 3 {
+		sigFile = os.Args[3]
+	}
+
+	if strings.Contains(flags, "h") || strings.Contains(flags, "?") {
+		help()
+		return "", srcFile, sigFile
+	}
+
+	if strings.Contains(flags, "g") && len(srcFile) == 0 {
+		fmt.Println("ERROR: key name is missing")
+		return "", srcFile, sigFile
+	}
+
+	if strings.Contains(flags, "t") {
+		if len(srcFile) != 0 && len(sigFile) == 0 {
+			sigFile, srcFile = srcFile, ""
+		}
+	} else if len(sigFile) == 0 && len(srcFile) != 0 {
+		sigFile = srcFile + SignatureExt
+	}
+
+	return flags, srcFile, sigFile
+}
+
+func main() {
+	flags, srcFile, sigFile := processCommandArgs()
+	if len(flags) == 0 {
+		return
+	}
+
+	defer crutils.ProveDataDestruction()
+	if strings.Contains(flags, "g") {
+		generateKeyPair(flags, srcFile)
+		return
+	}
+
+	data := getData(flags, srcFile)
+	if len(data) == 0 {
+		fmt.Println("Error: empty data")
+		return
+	}
+
+	if strings.Contains(flags, "v") {
+		verify(data, sigFile)
+	} else {
+		sign(flags, data, sigFile)
+	}
+}
+
+func getData(flags string, srcFile string) []byte {
+	if strings.Contains(flags, "t") {
+		fmt.Print("please enter the text: ")
+		return terminal.PlainTextInput()
+	}
+	if len(srcFile) == 0 {
+		srcFile = common.GetFileName()
+	}
+	data, err := ioutil.ReadFile(srcFile)
+	if err != nil {
+		fmt.Printf("Failed to load data: %s\n", err.Error())
+		return nil
+	}
+	return data
+}
+
+func generateKeyPair(flags string, name string) {
+	pub, priv, err := crutils.GenerateSigningKeyPair()
+	if err == nil {
+		err = common.SaveKeyPair(common.SigningKey, name, pub, priv, flags)
+		crutils.AnnihilateData(priv)
+	}
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+	} else {
+		fmt.Printf("Signing key pair [%s] saved, public key: %x\n", name, pub)
+	}
+}
+
+func sign(flags string, data []byte, sigFile string) {
+	priv, err := common.LoadPrivateKey(common.SigningKey, common.GetKeyName("signing key"), flags)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	defer crutils.AnnihilateData(priv)
+
+	sig, err := crutils.Sign(priv, data)
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+		return
+	}
+	fmt.Printf("signature: %x\n", sig)
+	if strings.Contains(flags, "f") {
+		common.SaveData(sigFile, []byte(hex.EncodeToString(sig)+"\n"))
+	}
+}
+
+func loadSignature(sigFile string) []byte {
+	if len(sigFile) != 0 {
+		var sig []byte
+		raw, err := ioutil.ReadFile(sigFile)
+		if err == nil {
+			sig, err = hex.DecodeString(strings.TrimSpace(string(raw)))
+			if err == nil {
+				return sig
+			}
+		}
+		fmt.Printf("Failed to load signature: %s\n", err.Error())
+	}
+	return common.GetHexData("signature")
+}
+
+func verify(data []byte, sigFile string) {
+	sig := loadSignature(sigFile)
+	pub, err := common.LoadPublicKey(common.SigningKey, common.GetKeyName("signer's public key"))
+	if err == nil {
+		err = crutils.Verify(pub, data, sig)
+	}
+	if err != nil {
+		fmt.Printf("Error: %s\n", err.Error())
+	} else {
+		fmt.Println("Signature is valid")
+	}
+}
@@ -1,6 +1,7 @@
 package main
 
 import (
+	"bytes"
 	"encoding/hex"
 	"fmt"
 	"os"
@@ -15,12 +16,16 @@ func help() {
 	fmt.Printf("xtext v.2.%d \n", crutils.CipherVersion)
 	fmt.Println("encrypt/decrypt short messages in console")
 	fmt.Println("USAGE: xtext flags [src]")
+	fmt.Println("if src is '-', the data is read from stdin, and only the result is written to stdout")
 	fmt.Println("\t -e encrypt")
 	fmt.Println("\t -d decrypt")
 	fmt.Println("\t -a reveal all decrypted data, including spacing")
 	fmt.Println("\t -w use weaker encryption (rcx + keccak without AES, MAC, salt and spacing)")
+	fmt.Println("\t -v add versioned header")
+	fmt.Println("\t -A armored output (base64 with BEGIN/END lines), armored input is detected automatically")
 	fmt.Println("\t -r random password")
 	fmt.Println("\t -s secure password input")
+	fmt.Println("\t -k combine password with keyfiles (certificate, arbitrary files, hex secret)")
 	fmt.Println("\t -S secure data input")
 	fmt.Println("\t -h help")
 }
@@ -52,6 +57,11 @@ func processParams() (flags string, data []byte) {
 		}
 	}
 
+	if strings.Contains(flags, "w") && strings.Contains(flags, "v") {
+		fmt.Println("Flags 'w' and 'v' are incompatible: weaker encryption does not support header")
+		return zero, nil
+	}
+
 	if strings.Contains(flags, "d") {
 		if strings.Contains(flags, "r") {
 			fmt.Println("Wrong flag 'r': you can not decrypt with random password")
@@ -72,6 +82,9 @@ func isWeakerAlgo(flags string, data []byte) bool {
 	}
 
 	if strings.Contains(flags, "d") {
+		if crutils.HasHeader(data) {
+			return false
+		}
 		threshold := crutils.MinDataSize*2 + crutils.EncryptedSizeDiff
 		if len(data) < threshold {
 			return true
@@ -87,12 +100,20 @@ func getData(flags string) (data []byte, err error) {
 		data = terminal.SecureInput(false)
 	} else {
 		fmt.Print("please enter the data: ")
-		data = terminal.PlainTextInput()
+		data = common.CompleteArmoredInput(terminal.PlainTextInput())
 	}
 	return data, err
 }
 
 func convertData(flags string, data []byte) (res []byte, err error) {
+	if common.IsArmored(data) {
+		_, _, res, err = common.Dearmor(data)
+		crutils.AnnihilateData(data)
+		if err != nil {
+			fmt.Printf("Error decoding armored data: %s\n", err.Error())
+		}
+		return res, err
+	}
 	if strings.Contains(flags, "d") || common.IsHexData(data) {
 		h := make([]byte, len(data)/2)
 		_, err = hex.Decode(h, data)
@@ -108,14 +129,43 @@ func convertData(flags string, data []byte) (res []byte, err error) {
 	return res, nil
 }
 
+var piped bool // the data is read from stdin, and the result is written to stdout
+
 func main() {
 	flags, data := processParams()
 	if len(flags) != 0 {
+		if string(data) == common.StdStream {
+			piped = true
+			data = loadStdin(flags)
+			if data == nil {
+				return
+			}
+		}
 		defer crutils.ProveDataDestruction()
 		run(flags, data)
 	}
 }
 
+func loadStdin(flags string) []byte {
+	if err := common.SetupStdStreams(common.StdStream, common.StdStream); err != nil {
+		fmt.Printf("ERROR: %s\n", err.Error())
+		return nil
+	}
+	data, err := common.LoadData(common.StdStream)
+	if err != nil {
+		fmt.Printf("ERROR: %s\n", err.Error())
+		return nil
+	}
+	if strings.Contains(flags, "d") {
+		data = bytes.TrimRight(data, "\r\n") // hex or armored text
+	}
+	if len(data) == 0 {
+		fmt.Println("ERROR: empty data")
+		return nil
+	}
+	return data
+}
+
 func run(flags string, data []byte) {
 	var err error
 	var res, key, spacing []byte
@@ -153,7 +203,9 @@ func process(flags string, key []byte, data []byte) (res []byte, spacing []byte,
 		}
 		res = data
 	} else {
-		if encryption {
+		if encryption && strings.Contains(flags, "v") {
+			res, err = crutils.EncryptWithHeader(crutils.NewHeader(crutils.ModeMain), key, data, nil)
+		} else if encryption {
 			res, err = crutils.Encrypt(key, data)
 		} else {
 			res, spacing, err = crutils.Decrypt(key, data)
@@ -170,7 +222,16 @@ func reportResult(flags string, err error, res []byte, spacing []byte) {
 	}
 
 	if strings.Contains(flags, "e") { // encryption
-		fmt.Printf("%x\n", res)
+		if strings.Contains(flags, "A") {
+			fmt.Fprintf(common.Stdout, "%s", common.ArmorCiphertext(res))
+		} else {
+			fmt.Fprintf(common.Stdout, "%x\n", res)
+		}
+		return
+	}
+
+	if piped {
+		common.Stdout.Write(res)
 		return
 	}
 
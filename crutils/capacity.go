@@ -0,0 +1,85 @@
+package crutils
+
+import "github.com/gluk256/crypto/algo/primitives"
+
+// Steganographic capacity. The content is padded to the power of two (four bytes reserved for the padding size),
+// and then interleaved with the spacing of the same size, which may hold the hidden content.
+// The hidden content is encrypted in the same way, therefore its encrypted size (2 * padded + EncryptedSizeDiff)
+// must not exceed the size of the face spacing. All the sizes are given before encryption (without header).
+
+type StegFit struct {
+	Face     int // the size of the face (outer) content
+	Hidden   int // the size of the hidden (inner) content
+	Capacity int // the maximum size of the hidden content for this face
+	MinFace  int // the minimum size of the face content for this hidden content (nested layers included)
+	Headroom int // negative if the hidden content does not fit
+}
+
+// nothing fits into the face without capacity, not even the empty content
+func (f *StegFit) Fits() bool {
+	return f.Capacity > 0 && f.Headroom >= 0
+}
+
+func PaddedSize(sz int) int {
+	res := primitives.FindNextPowerOfTwo(sz + 4)
+	if res < MinDataSize {
+		res = MinDataSize
+	}
+	return res
+}
+
+// the size of the content after encryption (the spacing included)
+func EncryptedSize(sz int) int {
+	return PaddedSize(sz)*2 + EncryptedSizeDiff
+}
+
+// the maximum size of the content, which can be hidden in the face content of the given size
+func StegCapacity(faceSize int) int {
+	p := PaddedSize(faceSize) / 4 // the padded size of the hidden content must be a power of two too
+	if p < MinDataSize {
+		return 0
+	}
+	return p - 4
+}
+
+// the minimum size of the face content, which can hold the hidden content of the given size
+func MinFaceSize(hiddenSize int) int {
+	return MinFaceSizeEncrypted(EncryptedSize(hiddenSize))
+}
+
+// the minimum size of the face content, which can hold the encrypted content of the given size
+func MinFaceSizeEncrypted(encryptedSize int) int {
+	p := primitives.FindNextPowerOfTwo(encryptedSize)
+	if p <= MinDataSize {
+		return 0
+	}
+	return p/2 - 3 // the face must be padded to the next power of two
+}
+
+// the encrypted content (e.g. encrypted file) can be hidden, if it does not exceed the face spacing
+func FitsEncrypted(faceSize int, encryptedSize int) bool {
+	return encryptedSize <= PaddedSize(faceSize)
+}
+
+// sizes of the nested layers, starting from the face: each next layer is hidden in the previous one.
+// returns the fit of each layer into the previous one.
+func PlanSteg(sizes ...int) []StegFit {
+	if len(sizes) < 2 {
+		return nil
+	}
+	res := make([]StegFit, len(sizes)-1)
+	required := sizes[len(sizes)-1]
+	for i := len(sizes) - 2; i >= 0; i-- {
+		f := &res[i]
+		f.Face = sizes[i]
+		f.Hidden = sizes[i+1]
+		f.Capacity = StegCapacity(f.Face)
+		f.Headroom = f.Capacity - f.Hidden
+		f.MinFace = MinFaceSize(required)
+		required = f.Face
+		if required < f.MinFace {
+			required = f.MinFace
+		}
+	}
+	return res
+}
@@ -0,0 +1,101 @@
+package crutils
+
+import (
+	mrand "math/rand"
+	"testing"
+	"time"
+)
+
+func TestStegCapacity(t *testing.T) {
+	seed := time.Now().Unix()
+	mrand.Seed(seed)
+	key := make([]byte, 32)
+	Randomize(key)
+
+	for i := 0; i < 8; i++ {
+		faceSize := mrand.Intn(4096)
+		face := make([]byte, faceSize)
+		Randomize(face)
+		capacity := StegCapacity(faceSize)
+		if capacity == 0 {
+			if faceSize >= MinFaceSize(0) {
+				t.Fatalf("zero capacity for face size %d, seed = %d", faceSize, seed)
+			}
+			continue
+		}
+
+		hidden := make([]byte, capacity)
+		Randomize(hidden)
+		encrypted, err := Encrypt(key, hidden)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(encrypted) != EncryptedSize(capacity) {
+			t.Fatalf("wrong encrypted size [%d vs. %d], seed = %d", len(encrypted), EncryptedSize(capacity), seed)
+		}
+		if !FitsEncrypted(faceSize, len(encrypted)) {
+			t.Fatalf("capacity %d does not fit face %d, seed = %d", capacity, faceSize, seed)
+		}
+		res, err := EncryptSteg(key, face, encrypted)
+		if err != nil {
+			t.Fatalf("failed to encrypt with capacity %d, face %d: %s, seed = %d", capacity, faceSize, err, seed)
+		}
+		_, steg, err := Decrypt(key, res)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if _, _, err = DecryptStegContentOfUnknownSize(key, steg); err != nil {
+			t.Fatalf("failed to decrypt steg, capacity %d, face %d, seed = %d", capacity, faceSize, seed)
+		}
+
+		// one more byte must not fit
+		if FitsEncrypted(faceSize, EncryptedSize(capacity+1)) {
+			t.Fatalf("capacity %d is not exact for face %d, seed = %d", capacity, faceSize, seed)
+		}
+		// the minimum face must hold the content, and the smaller face must not
+		min := MinFaceSize(capacity)
+		if StegCapacity(min) < capacity || StegCapacity(min-1) >= capacity {
+			t.Fatalf("wrong min face size %d for hidden size %d, seed = %d", min, capacity, seed)
+		}
+	}
+}
+
+func TestPlanSteg(t *testing.T) {
+	if PlanSteg(100) != nil {
+		t.Fatal("plan for single layer")
+	}
+
+	plan := PlanSteg(520, 130, 11)
+	if len(plan) != 2 {
+		t.Fatalf("wrong plan size %d", len(plan))
+	}
+	if !plan[0].Fits() || !plan[1].Fits() {
+		t.Fatal("expected to fit")
+	}
+	if plan[0].Capacity != 252 || plan[0].Headroom != 122 || plan[1].Capacity != 60 || plan[1].Headroom != 49 {
+		t.Fatalf("wrong plan: %v", plan)
+	}
+
+	// the middle layer is too small: the face must be big enough to hold the enlarged middle layer
+	plan = PlanSteg(1000, 10, 60)
+	if !plan[0].Fits() || plan[1].Fits() {
+		t.Fatalf("wrong plan: %v", plan)
+	}
+	if plan[1].MinFace != 125 || plan[0].MinFace != MinFaceSize(125) {
+		t.Fatalf("wrong min face size: %v", plan)
+	}
+}
+
+func TestPlanStegZeroCapacity(t *testing.T) {
+	if StegCapacity(10) != 0 {
+		t.Fatalf("unexpected capacity %d", StegCapacity(10))
+	}
+	plan := PlanSteg(10, 0)
+	if plan[0].Fits() {
+		t.Fatalf("empty content fits into zero capacity: %v", plan)
+	}
+	plan = PlanSteg(MinFaceSize(0), 0)
+	if !plan[0].Fits() {
+		t.Fatalf("empty content does not fit: %v", plan)
+	}
+}
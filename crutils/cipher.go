@@ -117,8 +117,7 @@ func EncryptInplaceKeccak(key []byte, data []byte) {
 	AnnihilateData(b)
 }
 
-// key is expected to be 32 bytes, salt 12 bytes
-func EncryptAES(key []byte, salt []byte, data []byte) ([]byte, error) {
+func newAesGcm(key []byte) (cipher.AEAD, error) {
 	if len(key) != AesKeySize {
 		return nil, fmt.Errorf("wrong key size %d", len(key))
 	}
@@ -126,64 +125,86 @@ func EncryptAES(key []byte, salt []byte, data []byte) ([]byte, error) {
 	if err != nil {
 		return nil, err
 	}
-	aesgcm, err := cipher.NewGCM(block)
-	if err != nil {
-		return nil, err
-	}
-	encrypted := aesgcm.Seal(data[:0], salt, data, nil)
-	return encrypted, err
+	return cipher.NewGCM(block)
+}
+
+// key is expected to be 32 bytes, salt 12 bytes
+func EncryptAES(key []byte, salt []byte, data []byte) ([]byte, error) {
+	return encryptAES(key, salt, data, nil)
 }
 
 // key is expected to be 32 bytes, salt 12 bytes
 func DecryptAES(key []byte, salt []byte, data []byte) ([]byte, error) {
-	if len(key) != AesKeySize {
-		return nil, fmt.Errorf("wrong key size %d", len(key))
-	}
-	block, err := aes.NewCipher(key)
+	return decryptAES(key, salt, data, nil)
+}
+
+// additional data (ad) is authenticated, but not encrypted
+func encryptAES(key []byte, salt []byte, data []byte, ad []byte) ([]byte, error) {
+	aesgcm, err := newAesGcm(key)
 	if err != nil {
 		return nil, err
 	}
-	aesgcm, err := cipher.NewGCM(block)
+	encrypted := aesgcm.Seal(data[:0], salt, data, ad)
+	return encrypted, err
+}
+
+func decryptAES(key []byte, salt []byte, data []byte, ad []byte) ([]byte, error) {
+	aesgcm, err := newAesGcm(key)
 	if err != nil {
 		return nil, err
 	}
-	decrypted, err := aesgcm.Open(data[:0], salt, data, nil)
+	decrypted, err := aesgcm.Open(data[:0], salt, data, ad)
 	return decrypted, err
 }
 
 // this is the main encryption function
 func Encrypt(key []byte, data []byte) ([]byte, error) {
-	data, _ = addPadding(data, 0, true)
-	spacing := make([]byte, len(data))
-	Randomize(spacing)
-	data = addSpacing(data, spacing) // now data will have additional capacity (enough for the salt)
-	return encrypt(key, data)
+	data, _ = addPaddingAndSpacing(data, nil)
+	return encrypt(key, data, nil)
 }
 
 // encrypt with steganographic content as spacing
 func EncryptSteg(key []byte, data []byte, steg []byte) (res []byte, err error) {
-	data, _ = addPadding(data, 0, true)
-	if len(data) < len(steg) { // four bytes for padding size
-		return nil, fmt.Errorf("data size is less than necessary [%d vs. %d]", len(data), len(steg)+4)
-	}
-	steg, err = addPadding(steg, len(data), false) // mark = false (steg content must be indistinguishable from random gamma)
+	data, err = addPaddingAndSpacing(data, steg)
 	if err != nil {
 		return nil, err
 	}
-	data = addSpacing(data, steg) // now data will have additional capacity (enough for the salt)
-	return encrypt(key, data)
+	return encrypt(key, data, nil)
+}
+
+// if steg is nil, the spacing is random
+func addPaddingAndSpacing(data []byte, steg []byte) (res []byte, err error) {
+	data, _ = addPadding(data, 0, true)
+	if steg == nil {
+		steg = make([]byte, len(data))
+		Randomize(steg)
+	} else {
+		if len(data) < len(steg) { // four bytes for padding size
+			return nil, fmt.Errorf("data size is less than necessary [%d vs. %d]", len(data), len(steg)+4)
+		}
+		steg, err = addPadding(steg, len(data), false) // mark = false (steg content must be indistinguishable from random gamma)
+		if err != nil {
+			return nil, err
+		}
+	}
+	return addSpacing(data, steg), nil // now data will have additional capacity (enough for the salt)
 }
 
 // data must be already padded.
+// the header (if any) is not included in the result, but the keys depend on it.
 // don't forget to annihilate the key!
-func encrypt(key []byte, data []byte) ([]byte, error) {
+func encrypt(key []byte, data []byte, header []byte) ([]byte, error) {
 	salt, err := GenerateSalt()
 	if err != nil {
 		return nil, err
 	}
 
-	keyholder := GenerateKeys(key, salt)
-	defer AnnihilateData(keyholder)
+	kh, err := generateKeysWithHeader(key, salt, header)
+	if err != nil {
+		return nil, err
+	}
+	defer kh.Destroy()
+	keyholder := kh.Bytes()
 
 	EncryptInplaceKeccak(getKey1(keyholder), data)
 	EncryptInplaceRCX(getRcxKey(keyholder), data)
@@ -205,15 +226,37 @@ func encrypt(key []byte, data []byte) ([]byte, error) {
 	return res, nil
 }
 
+// decrypts the data with or without header (legacy format).
 // don't forget to annihilate the key!
 func Decrypt(key []byte, data []byte) (res []byte, spacing []byte, err error) {
+	if HasMagic(data) {
+		h, err := ParseHeader(data)
+		if err == nil {
+			return decryptWithHeader(key, h, data)
+		}
+		// legacy data might start with magic by chance, although it is very unlikely
+		res, spacing, errLegacy := decrypt(key, data, nil)
+		if errLegacy == nil {
+			return res, spacing, nil
+		}
+		return nil, nil, err
+	}
+	return decrypt(key, data, nil)
+}
+
+// decrypts the data encrypted by encrypt() with the same header
+func decrypt(key []byte, data []byte, header []byte) (res []byte, spacing []byte, err error) {
 	if len(data) <= SaltSize {
 		return nil, nil, fmt.Errorf("data size %d, less than salt size %d", len(data), SaltSize)
 	}
 	res = data[:len(data)-SaltSize]
 	salt := data[len(data)-SaltSize:]
-	keyholder := GenerateKeys(key, salt)
-	defer AnnihilateData(keyholder)
+	kh, err := generateKeysWithHeader(key, salt, header)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer kh.Destroy()
+	keyholder := kh.Bytes()
 
 	EncryptInplaceKeccak(getKey2(keyholder), res)
 	res, err = DecryptAES(getAesKey(keyholder), getAesSalt(keyholder), res)
@@ -246,12 +289,29 @@ func DecryptStegContentOfUnknownSize(key []byte, steg []byte) ([]byte, []byte, e
 
 // XOR-only encryption, without the slow block cipher
 func EncryptQuick(key []byte, data []byte) ([]byte, error) {
+	return encryptQuick(key, data, nil)
+}
+
+// decrypts the data with or without header (legacy format)
+func DecryptQuick(key []byte, data []byte) ([]byte, error) {
+	if HasHeader(data) {
+		res, _, err := Decrypt(key, data)
+		return res, err
+	}
+	return decryptQuick(key, data, nil)
+}
+
+func encryptQuick(key []byte, data []byte, header []byte) ([]byte, error) {
 	salt, err := GenerateSalt()
 	if err != nil {
 		return nil, err
 	}
-	keyholder := GenerateKeys(key, salt)
-	defer AnnihilateData(keyholder)
+	kh, err := generateKeysWithHeader(key, salt, header)
+	if err != nil {
+		return nil, err
+	}
+	defer kh.Destroy()
+	keyholder := kh.Bytes()
 
 	rcx.EncryptInplaceRC4(getRcxKey(keyholder), data)
 	EncryptInplaceKeccak(getKey1(keyholder), data)
@@ -263,13 +323,20 @@ func EncryptQuick(key []byte, data []byte) ([]byte, error) {
 	return data, err
 }
 
-func DecryptQuick(key []byte, data []byte) ([]byte, error) {
+func decryptQuick(key []byte, data []byte, header []byte) ([]byte, error) {
 	var err error
+	if len(data) <= SaltSize {
+		return nil, fmt.Errorf("data size %d, less than salt size %d", len(data), SaltSize)
+	}
 	split := len(data) - SaltSize
 	salt := data[split:]
 	data = data[:split]
-	keyholder := GenerateKeys(key, salt)
-	defer AnnihilateData(keyholder)
+	kh, err := generateKeysWithHeader(key, salt, header)
+	if err != nil {
+		return nil, err
+	}
+	defer kh.Destroy()
+	keyholder := kh.Bytes()
 
 	data, err = DecryptAES(getAesKey(keyholder), getAesSalt(keyholder), data)
 	if err != nil {
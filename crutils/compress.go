@@ -0,0 +1,85 @@
+package crutils
+
+import (
+	"bytes"
+	"compress/flate"
+	"errors"
+	"io"
+)
+
+// Optional compression (DEFLATE) in the main mode, applied before padding.
+// Compression is marked by the header flag, which is authenticated along with the rest of the header.
+// Please note that compression might leak some information about the content via the size of the ciphertext,
+// although the padding (to the power of two) hides most of it.
+
+const (
+	FlagCompressed = 1 << iota
+	flagsEnd
+)
+
+var MaxDecompressedSize = 1024 * 1024 * 1024 // protection against decompression bombs
+
+func (h *Header) IsCompressed() bool {
+	return h.Flags&FlagCompressed != 0
+}
+
+// the source data is annihilated.
+// don't forget to annihilate the result!
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(data) + len(data)/64 + 64) // worst case, avoid reallocation of sensitive data
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err == nil {
+		_, err = w.Write(data)
+	}
+	if err == nil {
+		err = w.Close()
+	}
+	AnnihilateData(data)
+	if err != nil {
+		AnnihilateData(buf.Bytes())
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+// the source data is annihilated.
+// don't forget to annihilate the result!
+func decompress(data []byte) ([]byte, error) {
+	fr := flate.NewReader(bytes.NewReader(data))
+	defer fr.Close()
+	defer AnnihilateData(data)
+	r := io.LimitReader(fr, int64(MaxDecompressedSize)+1) // one extra byte to detect the excess
+
+	sz := len(data)*4 + 64
+	if sz > MaxDecompressedSize+1 {
+		sz = MaxDecompressedSize + 1
+	}
+	res := make([]byte, 0, sz)
+	for {
+		if len(res) > MaxDecompressedSize {
+			AnnihilateData(res)
+			return nil, errors.New("decompressed data is too big")
+		}
+		if len(res) == cap(res) {
+			// grow manually, so that the previous buffer is annihilated
+			sz := cap(res) * 2
+			if sz > MaxDecompressedSize+1 {
+				sz = MaxDecompressedSize + 1
+			}
+			tmp := make([]byte, len(res), sz)
+			copy(tmp, res)
+			AnnihilateData(res)
+			res = tmp
+		}
+		n, err := r.Read(res[len(res):cap(res)])
+		res = res[:len(res)+n]
+		if err == io.EOF && len(res) <= MaxDecompressedSize {
+			return res, nil
+		}
+		if err != nil && err != io.EOF {
+			AnnihilateData(res)
+			return nil, err
+		}
+	}
+}
@@ -0,0 +1,112 @@
+package crutils
+
+import (
+	"bytes"
+	mrand "math/rand"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestCompress(t *testing.T) {
+	seed := time.Now().Unix()
+	mrand.Seed(seed)
+
+	for _, sz := range []int{0, 1, 100, 4096, 100000} {
+		data := make([]byte, sz)
+		mrand.Read(data)
+		orig := make([]byte, sz)
+		copy(orig, data)
+
+		c, err := compress(data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		d, err := decompress(c)
+		if err != nil {
+			t.Fatalf("size %d, seed %d: %s", sz, seed, err)
+		}
+		if !bytes.Equal(d, orig) {
+			t.Fatalf("decompressed != original, size %d, seed %d", sz, seed)
+		}
+	}
+
+	if _, err := decompress([]byte("definitely not deflate stream")); err == nil {
+		t.Fatal("wrong data decompressed")
+	}
+}
+
+func TestDecompressionLimit(t *testing.T) {
+	prev := MaxDecompressedSize
+	MaxDecompressedSize = 100000
+	defer func() { MaxDecompressedSize = prev }()
+
+	for _, sz := range []int{MaxDecompressedSize - 1, MaxDecompressedSize} {
+		c, err := compress(make([]byte, sz))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if d, err := decompress(c); err != nil || len(d) != sz {
+			t.Fatalf("size %d: %v", sz, err)
+		}
+	}
+
+	// the bomb: highly compressible, and exceeding the limit by one byte only
+	c, err := compress(make([]byte, MaxDecompressedSize+1))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = decompress(c); err == nil {
+		t.Fatal("decompressed data exceeds the limit")
+	}
+}
+
+func TestEncryptionWithCompression(t *testing.T) {
+	seed := time.Now().Unix()
+	mrand.Seed(seed)
+
+	key := generateRandomBytes(t, false)
+	text := []byte(strings.Repeat("the quick brown fox jumps over the lazy dog\n", 1000))
+	data := make([]byte, len(text))
+	copy(data, text)
+
+	h := NewHeader(ModeMain)
+	h.Flags = FlagCompressed
+	encrypted, err := EncryptWithHeader(h, key, data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(encrypted) >= len(text) {
+		t.Fatalf("compression failed: %d vs. %d", len(encrypted), len(text))
+	}
+	p, err := ParseHeader(encrypted)
+	if err != nil || !p.IsCompressed() {
+		t.Fatal("compression flag is lost")
+	}
+
+	tampered := make([]byte, len(encrypted))
+	copy(tampered, encrypted)
+	tampered[7] = 0 // flags are authenticated
+	if _, _, err = Decrypt(key, tampered); err == nil {
+		t.Fatal("modified flags not detected")
+	}
+
+	decrypted, _, err := Decrypt(key, encrypted)
+	if err != nil {
+		t.Fatalf("seed %d: %s", seed, err)
+	}
+	if !bytes.Equal(decrypted, text) {
+		t.Fatalf("decrypted != expected, seed %d", seed)
+	}
+
+	h = NewHeader(ModeQuick)
+	h.Flags = FlagCompressed
+	if _, err = EncryptWithHeader(h, key, text, nil); err == nil {
+		t.Fatal("compression accepted in quick mode")
+	}
+	h = NewHeader(ModeMain)
+	h.Flags = flagsEnd
+	if _, err = EncryptWithHeader(h, key, text, nil); err == nil {
+		t.Fatal("unknown flags accepted")
+	}
+}
@@ -0,0 +1,242 @@
+package crutils
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io/ioutil"
+)
+
+// The header is optional. It describes the format of the encrypted data, so that the decryption
+// does not need to guess. The header is not encrypted, but it is authenticated, because the keys
+// are derived from the header along with the salt.
+//
+// The header reveals that the data is encrypted by this package, therefore it must never be used
+// for steganographic content (which must be indistinguishable from random gamma).
+// For the same reason there is no separate mode for the face content with steganographic spacing:
+// it is encrypted in the main mode, and nobody can tell if the spacing contains anything.
+//
+// header layout: [magic][version][mode][kdf][flags][kdf_params][chunk_size]
+// chunk size is mandatory in stream mode, optional in quick mode (see quick.go), and not allowed otherwise.
+// flags: compression (see compress.go), only in the main mode.
+
+const (
+	HeaderMagic  = "XCRY"
+	HeaderSize   = 24
+	MaxChunkSize = 1024 * 1024 * 64
+)
+
+const (
+	ModeMain = iota + 1
+	ModeQuick
+	ModeStream
+	ModePublicKey
+	ModeSlots
+	modeEnd
+)
+
+type Header struct {
+	Version   byte
+	Mode      byte
+	Kdf       byte
+	Flags     byte
+	KdfParams [3]uint32
+	ChunkSize uint32
+}
+
+func NewHeader(mode byte) *Header {
+	h := &Header{Version: CipherVersion, Mode: mode, Kdf: KdfKeccak}
+	if mode == ModeStream {
+		h.ChunkSize = StreamChunkSize
+	}
+	return h
+}
+
+func ModeName(mode byte) string {
+	switch mode {
+	case ModeMain:
+		return "main"
+	case ModeQuick:
+		return "quick"
+	case ModeStream:
+		return "stream"
+	case ModePublicKey:
+		return "public key"
+	case ModeSlots:
+		return "multi-recipient"
+	default:
+		return "unknown"
+	}
+}
+
+func (h *Header) String() string {
+	return fmt.Sprintf("version %d, mode %s, kdf %s %v, flags %x, chunk %d",
+		h.Version, ModeName(h.Mode), KdfName(h.Kdf), h.KdfParams, h.Flags, h.ChunkSize)
+}
+
+func (h *Header) Bytes() []byte {
+	b := make([]byte, HeaderSize)
+	copy(b, HeaderMagic)
+	b[4] = h.Version
+	b[5] = h.Mode
+	b[6] = h.Kdf
+	b[7] = h.Flags
+	for i, p := range h.KdfParams {
+		binary.LittleEndian.PutUint32(b[8+i*4:], p)
+	}
+	binary.LittleEndian.PutUint32(b[20:], h.ChunkSize)
+	return b
+}
+
+func (h *Header) validate() error {
+	if h.Version != CipherVersion {
+		return fmt.Errorf("unsupported cipher version %d", h.Version)
+	}
+	if h.Mode == 0 || h.Mode >= modeEnd {
+		return fmt.Errorf("unknown mode %d", h.Mode)
+	}
+	if err := validateKdfParams(h.Kdf, h.KdfParams); err != nil {
+		return err
+	}
+	if h.Flags >= flagsEnd {
+		return fmt.Errorf("unknown flags %x", h.Flags)
+	}
+	if h.IsCompressed() && h.Mode != ModeMain {
+		return fmt.Errorf("compression is not supported in %s mode", ModeName(h.Mode))
+	}
+	if h.Mode == ModeStream || (h.Mode == ModeQuick && h.ChunkSize != 0) {
+		if h.ChunkSize == 0 || h.ChunkSize > MaxChunkSize {
+			return fmt.Errorf("wrong chunk size %d", h.ChunkSize)
+		}
+	} else if h.ChunkSize != 0 {
+		return fmt.Errorf("chunk size is not allowed in %s mode", ModeName(h.Mode))
+	}
+	return nil
+}
+
+func HasMagic(data []byte) bool {
+	return len(data) >= HeaderSize && bytes.Equal(data[:len(HeaderMagic)], []byte(HeaderMagic))
+}
+
+func HasHeader(data []byte) bool {
+	_, err := ParseHeader(data)
+	return err == nil
+}
+
+func ParseHeader(data []byte) (*Header, error) {
+	if !HasMagic(data) {
+		return nil, errors.New("header not found")
+	}
+	h := &Header{
+		Version:   data[4],
+		Mode:      data[5],
+		Kdf:       data[6],
+		Flags:     data[7],
+		ChunkSize: binary.LittleEndian.Uint32(data[20:]),
+	}
+	for i := range h.KdfParams {
+		h.KdfParams[i] = binary.LittleEndian.Uint32(data[8+i*4:])
+	}
+	if err := h.validate(); err != nil {
+		return nil, fmt.Errorf("wrong header: %s", err.Error())
+	}
+	return h, nil
+}
+
+// encrypts the data in the format specified by the header, and prepends the header.
+// steg content is only allowed in the main mode, and might be nil.
+// don't forget to annihilate the key!
+func EncryptWithHeader(h *Header, key []byte, data []byte, steg []byte) (res []byte, err error) {
+	if err = h.validate(); err != nil {
+		return nil, err
+	}
+	if steg != nil && h.Mode != ModeMain {
+		return nil, fmt.Errorf("steganographic content is not allowed in %s mode", ModeName(h.Mode))
+	}
+
+	hdr := h.Bytes()
+	var body []byte
+	switch h.Mode {
+	case ModeMain:
+		if h.IsCompressed() {
+			data, err = compress(data)
+			if err != nil {
+				return nil, err
+			}
+		}
+		data, err = addPaddingAndSpacing(data, steg)
+		if err == nil {
+			body, err = encrypt(key, data, hdr)
+		}
+	case ModeQuick:
+		if h.ChunkSize != 0 {
+			return encryptQuickChunkedData(h, hdr, key, data)
+		}
+		body, err = encryptQuick(key, data, hdr)
+	case ModeStream:
+		var buf bytes.Buffer
+		buf.Write(hdr)
+		var w *streamWriter
+		w, err = newStreamWriter(h, key, &buf)
+		if err == nil {
+			_, err = w.Write(data)
+			AnnihilateData(data)
+		}
+		if err == nil {
+			err = w.Close()
+		}
+		return buf.Bytes(), err
+	case ModePublicKey:
+		return nil, errors.New("public key encryption requires EncryptToPublicKey()")
+	case ModeSlots:
+		return nil, errors.New("multi-recipient encryption requires EncryptForRecipients()")
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	res = make([]byte, 0, len(hdr)+len(body))
+	res = append(res, hdr...)
+	res = append(res, body...)
+	AnnihilateData(body)
+	return res, nil
+}
+
+func decryptWithHeader(key []byte, h *Header, data []byte) (res []byte, spacing []byte, err error) {
+	hdr := data[:HeaderSize]
+	body := data[HeaderSize:]
+	switch h.Mode {
+	case ModeMain:
+		res, spacing, err = decrypt(key, body, hdr)
+		if err == nil && h.IsCompressed() {
+			res, err = decompress(res)
+		}
+		return res, spacing, err
+	case ModeQuick:
+		if h.ChunkSize != 0 {
+			res, err = decryptQuickChunkedData(h, hdr, key, body)
+		} else {
+			res, err = decryptQuick(key, body, hdr)
+		}
+		return res, nil, err
+	case ModeStream:
+		var r *streamReader
+		r, err = newStreamReader(h, hdr, key, bytes.NewReader(body))
+		if err != nil {
+			return nil, nil, err
+		}
+		defer r.Close()
+		res, err = ioutil.ReadAll(r)
+		if err != nil {
+			AnnihilateData(res)
+			return nil, nil, err
+		}
+		return res, nil, nil
+	case ModePublicKey:
+		return nil, nil, errors.New("private key is required for decryption")
+	case ModeSlots:
+		return decryptWithSlots(key, false, data)
+	}
+	return nil, nil, fmt.Errorf("unknown mode %d", h.Mode)
+}
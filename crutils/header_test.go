@@ -0,0 +1,135 @@
+package crutils
+
+import (
+	"bytes"
+	mrand "math/rand"
+	"testing"
+	"time"
+)
+
+func TestHeaderSerialization(t *testing.T) {
+	h := NewHeader(ModeStream)
+	h.KdfParams = [3]uint32{1, 0xffffffff, 77}
+	b := h.Bytes()
+	if len(b) != HeaderSize {
+		t.Fatalf("wrong header size %d", len(b))
+	}
+	p, err := ParseHeader(b)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *p != *h {
+		t.Fatalf("parsed header differs from original: [%s] vs. [%s]", p, h)
+	}
+
+	b[4]++
+	if HasHeader(b) {
+		t.Fatal("wrong version accepted")
+	}
+	b[4]--
+	b[5] = modeEnd
+	if HasHeader(b) {
+		t.Fatal("wrong mode accepted")
+	}
+	b[5] = ModeMain
+	if HasHeader(b) {
+		t.Fatal("chunk size accepted in main mode")
+	}
+	b[0]++
+	if HasMagic(b) || HasHeader(b) {
+		t.Fatal("wrong magic accepted")
+	}
+}
+
+func TestEncryptionWithHeader(t *testing.T) {
+	seed := time.Now().Unix()
+	mrand.Seed(seed)
+
+	for _, mode := range []byte{ModeMain, ModeQuick, ModeStream} {
+		key := generateRandomBytes(t, false)
+		data := generateRandomBytes(t, true)
+		orig := make([]byte, len(data))
+		copy(orig, data)
+
+		encrypted, err := EncryptWithHeader(NewHeader(mode), key, data, nil)
+		if err != nil {
+			t.Fatalf("mode %d: %s", mode, err)
+		}
+		h, err := ParseHeader(encrypted)
+		if err != nil {
+			t.Fatalf("mode %d: %s", mode, err)
+		}
+		if h.Mode != mode {
+			t.Fatalf("wrong mode %d vs. %d", h.Mode, mode)
+		}
+
+		tampered := make([]byte, len(encrypted))
+		copy(tampered, encrypted)
+		tampered[8]++ // kdf params are not used in this mode, but still authenticated
+		if _, _, err = Decrypt(key, tampered); err == nil {
+			t.Fatalf("mode %d: modified header not detected", mode)
+		}
+
+		dup := make([]byte, len(encrypted))
+		copy(dup, encrypted)
+		decrypted, err := DecryptQuick(key, dup)
+		if err != nil {
+			t.Fatalf("mode %d, seed %d: %s", mode, seed, err)
+		}
+		if !bytes.Equal(decrypted, orig) {
+			t.Fatalf("mode %d: decrypted != expected, seed %d", mode, seed)
+		}
+
+		decrypted, _, err = Decrypt(key, encrypted)
+		if err != nil {
+			t.Fatalf("mode %d, seed %d: %s", mode, seed, err)
+		}
+		if !bytes.Equal(decrypted, orig) {
+			t.Fatalf("mode %d: decrypted != expected, seed %d", mode, seed)
+		}
+	}
+}
+
+func TestEncryptionWithHeaderSteg(t *testing.T) {
+	seed := time.Now().Unix()
+	mrand.Seed(seed)
+
+	key := generateRandomBytes(t, false)
+	keySteg := generateRandomBytes(t, false)
+	steg := generateRandomBytes(t, false)
+	origSteg := make([]byte, len(steg))
+	copy(origSteg, steg)
+	encryptedSteg, err := Encrypt(keySteg, steg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if HasHeader(encryptedSteg) {
+		t.Fatal("steg content has header")
+	}
+
+	data := generateRandomBytesMinSize(t, len(encryptedSteg))
+	origData := make([]byte, len(data))
+	copy(origData, data)
+	if _, err = EncryptWithHeader(NewHeader(ModeQuick), key, data, encryptedSteg); err == nil {
+		t.Fatal("steg content accepted in quick mode")
+	}
+
+	encrypted, err := EncryptWithHeader(NewHeader(ModeMain), key, data, encryptedSteg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, raw, err := Decrypt(key, encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, origData) {
+		t.Fatalf("failed to decrypt data, with seed %d", seed)
+	}
+	decryptedSteg, _, err := DecryptStegContentOfUnknownSize(keySteg, raw)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decryptedSteg, origSteg) {
+		t.Fatalf("failed to decrypt steg, with seed %d", seed)
+	}
+}
@@ -0,0 +1,119 @@
+package crutils
+
+import (
+	"fmt"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/scrypt"
+)
+
+// The key derivation function (KDF) is applied to the key (which is already digested by the caller,
+// e.g. common.GetPassword), with the same salt that is used for the encryption.
+// The KDF and its parameters are stored in the header, so that the decryption picks them up automatically.
+// Memory-hard KDF makes the offline password guessing expensive.
+
+const (
+	KdfKeccak   = iota // the key is digested by the caller, nothing else to do (compatible with the legacy format)
+	KdfArgon2id        // params: [time, memory (KiB), threads]
+	KdfScrypt          // params: [log2(N), r, p]
+	kdfEnd
+)
+
+const (
+	KdfKeySize = 256 // the keys for all crypto apps must always be 256 bytes
+
+	// the params come from the header before authentication, so they must not crash or stall the decryption
+	kdfMaxMemory = 1 << 30 // bytes
+
+	argon2DefaultTime    = 3
+	argon2DefaultMemory  = 64 * 1024
+	argon2DefaultThreads = 4
+	argon2MaxTime        = 16
+	argon2MaxMemory      = kdfMaxMemory / 1024 // KiB
+
+	scryptDefaultLogN = 16
+	scryptDefaultR    = 8
+	scryptDefaultP    = 1
+	scryptMaxLogN     = 22
+	scryptMaxR        = 32
+	scryptMaxP        = 16
+	scryptMaxWork     = 1 << 25 // N*r*p, the default is 1 << 19
+)
+
+func KdfName(kdf byte) string {
+	switch kdf {
+	case KdfKeccak:
+		return "keccak"
+	case KdfArgon2id:
+		return "argon2id"
+	case KdfScrypt:
+		return "scrypt"
+	default:
+		return "unknown"
+	}
+}
+
+// sets the kdf with default params
+func (h *Header) SetKdf(kdf byte) {
+	h.Kdf = kdf
+	switch kdf {
+	case KdfArgon2id:
+		h.KdfParams = [3]uint32{argon2DefaultTime, argon2DefaultMemory, argon2DefaultThreads}
+	case KdfScrypt:
+		h.KdfParams = [3]uint32{scryptDefaultLogN, scryptDefaultR, scryptDefaultP}
+	default:
+		h.KdfParams = [3]uint32{}
+	}
+}
+
+// the params are checked before decryption, since they come from untrusted source
+func validateKdfParams(kdf byte, p [3]uint32) error {
+	switch kdf {
+	case KdfKeccak:
+		return nil
+	case KdfArgon2id:
+		if p[0] == 0 || p[0] > argon2MaxTime {
+			return fmt.Errorf("wrong argon2 time %d", p[0])
+		}
+		if p[2] == 0 || p[2] > 255 {
+			return fmt.Errorf("wrong argon2 threads %d", p[2])
+		}
+		if p[1] < 8*p[2] || p[1] > argon2MaxMemory {
+			return fmt.Errorf("wrong argon2 memory %d", p[1])
+		}
+	case KdfScrypt:
+		if p[0] < 1 || p[0] > scryptMaxLogN {
+			return fmt.Errorf("wrong scrypt log2(N) %d", p[0])
+		}
+		if p[1] == 0 || p[1] > scryptMaxR {
+			return fmt.Errorf("wrong scrypt r %d", p[1])
+		}
+		if p[2] == 0 || p[2] > scryptMaxP {
+			return fmt.Errorf("wrong scrypt p %d", p[2])
+		}
+		n := uint64(1) << p[0]
+		if 128*uint64(p[1])*n > kdfMaxMemory || n*uint64(p[1])*uint64(p[2]) > scryptMaxWork {
+			return fmt.Errorf("scrypt params %v exceed the limits", p)
+		}
+	default:
+		return fmt.Errorf("unknown kdf %d", kdf)
+	}
+	return nil
+}
+
+// don't forget to annihilate the result!
+func deriveKey(kdf byte, params [3]uint32, key []byte, salt []byte) ([]byte, error) {
+	if err := validateKdfParams(kdf, params); err != nil {
+		return nil, err
+	}
+	switch kdf {
+	case KdfArgon2id:
+		return argon2.IDKey(key, salt, params[0], params[1], uint8(params[2]), KdfKeySize), nil
+	case KdfScrypt:
+		return scrypt.Key(key, salt, 1<<params[0], int(params[1]), int(params[2]), KdfKeySize)
+	default:
+		res := make([]byte, len(key))
+		copy(res, key)
+		return res, nil
+	}
+}
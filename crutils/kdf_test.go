@@ -0,0 +1,144 @@
+package crutils
+
+import (
+	"bytes"
+	mrand "math/rand"
+	"testing"
+	"time"
+)
+
+// cheap params for the tests only
+var testKdfParams = map[byte][3]uint32{
+	KdfKeccak:   {},
+	KdfArgon2id: {1, 1024, 1},
+	KdfScrypt:   {10, 8, 1},
+}
+
+func TestDeriveKey(t *testing.T) {
+	key := []byte("7eab42de4c3ceb9235fc91acffe746b29c29a8c366b7c60e4e67c466f36a4304")
+	salt := []byte("c00fa9caf9d87976ba469bcbe06713b4")
+	for kdf := byte(KdfArgon2id); kdf < kdfEnd; kdf++ {
+		params := testKdfParams[kdf]
+		k1, err := deriveKey(kdf, params, key, salt)
+		if err != nil {
+			t.Fatalf("kdf %s: %s", KdfName(kdf), err)
+		}
+		k2, err := deriveKey(kdf, params, key, salt)
+		if err != nil {
+			t.Fatalf("kdf %s: %s", KdfName(kdf), err)
+		}
+		if len(k1) != KdfKeySize || !bytes.Equal(k1, k2) {
+			t.Fatalf("kdf %s is not deterministic", KdfName(kdf))
+		}
+		if bytes.Contains(k1, key[:8]) {
+			t.Fatalf("kdf %s: key is not transformed", KdfName(kdf))
+		}
+
+		params[0]++
+		k3, err := deriveKey(kdf, params, key, salt)
+		if err != nil {
+			t.Fatalf("kdf %s: %s", KdfName(kdf), err)
+		}
+		if bytes.Equal(k1, k3) {
+			t.Fatalf("kdf %s ignores params", KdfName(kdf))
+		}
+	}
+}
+
+func TestKdfParamsValidation(t *testing.T) {
+	wrong := []struct {
+		kdf    byte
+		params [3]uint32
+	}{
+		{KdfArgon2id, [3]uint32{0, 1024, 1}},
+		{KdfArgon2id, [3]uint32{1, 4, 1}},
+		{KdfArgon2id, [3]uint32{1, argon2MaxMemory + 1, 1}},
+		{KdfArgon2id, [3]uint32{1, 1024, 0}},
+		{KdfScrypt, [3]uint32{0, 8, 1}},
+		{KdfScrypt, [3]uint32{scryptMaxLogN + 1, 8, 1}},
+		{KdfScrypt, [3]uint32{10, 0, 1}},
+		{KdfScrypt, [3]uint32{10, 8, 0}},
+		{kdfEnd, [3]uint32{}},
+		// huge params, which would crash or stall the decryption
+		{KdfArgon2id, [3]uint32{1024, 4 * 1024 * 1024, 4}},
+		{KdfArgon2id, [3]uint32{argon2MaxTime + 1, 1024, 1}},
+		{KdfScrypt, [3]uint32{30, 64, 1}},
+		{KdfScrypt, [3]uint32{scryptMaxLogN, 8, 1}}, // 4 GiB
+		{KdfScrypt, [3]uint32{20, 8, 16}},
+	}
+	for i, w := range wrong {
+		h := NewHeader(ModeMain)
+		h.Kdf = w.kdf
+		h.KdfParams = w.params
+		if HasHeader(h.Bytes()) {
+			t.Fatalf("wrong params accepted, case %d", i)
+		}
+	}
+}
+
+func TestEncryptionWithKdf(t *testing.T) {
+	seed := time.Now().Unix()
+	mrand.Seed(seed)
+
+	for kdf := byte(KdfKeccak); kdf < kdfEnd; kdf++ {
+		for _, mode := range []byte{ModeMain, ModeQuick} {
+			key := generateRandomBytes(t, false)
+			data := generateRandomBytes(t, false)
+			orig := make([]byte, len(data))
+			copy(orig, data)
+
+			h := NewHeader(mode)
+			h.SetKdf(kdf)
+			h.KdfParams = testKdfParams[kdf]
+			encrypted, err := EncryptWithHeader(h, key, data, nil)
+			if err != nil {
+				t.Fatalf("kdf %s: %s", KdfName(kdf), err)
+			}
+
+			p, err := ParseHeader(encrypted)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if p.Kdf != kdf || p.KdfParams != h.KdfParams {
+				t.Fatalf("kdf %s: wrong header [%s]", KdfName(kdf), p)
+			}
+
+			decrypted, _, err := Decrypt(key, encrypted)
+			if err != nil {
+				t.Fatalf("kdf %s, seed %d: %s", KdfName(kdf), seed, err)
+			}
+			if !bytes.Equal(decrypted, orig) {
+				t.Fatalf("kdf %s: decrypted != expected, seed %d", KdfName(kdf), seed)
+			}
+		}
+	}
+}
+
+func TestSetKdf(t *testing.T) {
+	for kdf := byte(KdfKeccak); kdf < kdfEnd; kdf++ {
+		h := NewHeader(ModeMain)
+		h.SetKdf(kdf)
+		if !HasHeader(h.Bytes()) {
+			t.Fatalf("default params of %s are not valid", KdfName(kdf))
+		}
+	}
+}
+
+func TestHugeKdfParamsRejected(t *testing.T) {
+	key := generateRandomBytes(t, false)
+	data := generateRandomBytes(t, false)
+	h := NewHeader(ModeMain)
+	h.SetKdf(KdfScrypt)
+	h.KdfParams = testKdfParams[KdfScrypt]
+	encrypted, err := EncryptWithHeader(h, key, data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// crafted header: 128 * r * N = 8 TiB
+	h.KdfParams = [3]uint32{30, 64, 1}
+	copy(encrypted, h.Bytes())
+	if _, _, err = Decrypt(key, encrypted); err == nil {
+		t.Fatal("huge kdf params accepted")
+	}
+}
@@ -108,3 +108,40 @@ func GenerateKeys(key []byte, salt []byte) []byte {
 	AnnihilateData(fullkey)
 	return keyholder
 }
+
+// the same as GenerateKeys, but the keys (and the intermediate data) never leave the locked memory
+func generateKeyholder(key []byte, salt []byte) *SecureBuffer {
+	fullkey := NewSecureBuffer(len(key) + len(salt))
+	copy(fullkey.Bytes(), key)
+	copy(fullkey.Bytes()[len(key):], salt)
+	keyholder := NewSecureBuffer(getKeyHolderSize())
+	var k keccak.Keccak512
+	k.Write(fullkey.Bytes())
+	k.Read(keyholder.Bytes())
+	k.Reset()
+	fullkey.Destroy()
+	return keyholder
+}
+
+// the header (if any) is authenticated implicitly: any modification would result in completely different keys.
+// the key derivation function specified in the header is applied to the key before generating the keys.
+// don't forget to destroy the result!
+func generateKeysWithHeader(key []byte, salt []byte, header []byte) (*SecureBuffer, error) {
+	if len(header) == 0 {
+		return generateKeyholder(key, salt), nil
+	}
+	h, err := ParseHeader(header)
+	if err != nil {
+		return nil, err
+	}
+	derived, err := deriveKey(h.Kdf, h.KdfParams, key, salt)
+	if err != nil {
+		return nil, err
+	}
+	fullsalt := make([]byte, 0, len(salt)+len(header))
+	fullsalt = append(fullsalt, salt...)
+	fullsalt = append(fullsalt, header...)
+	keyholder := generateKeyholder(derived, fullsalt)
+	AnnihilateData(derived)
+	return keyholder, nil
+}
@@ -0,0 +1,127 @@
+package crutils
+
+import (
+	"errors"
+	"fmt"
+
+	"golang.org/x/crypto/curve25519"
+
+	"github.com/gluk256/crypto/algo/keccak"
+)
+
+// Hybrid encryption: the shared secret is derived via X25519 from the ephemeral key pair
+// and the recipient's public key, then the data is encrypted with the main encryption function.
+//
+// layout: [header][ephemeral_public_key][encrypted_data][salt]
+
+const (
+	PublicKeySize  = curve25519.PointSize
+	PrivateKeySize = curve25519.ScalarSize
+)
+
+// don't forget to annihilate the private key!
+func GenerateKeyPair() (pub []byte, priv []byte, err error) {
+	priv = make([]byte, PrivateKeySize)
+	err = StochasticRand(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	pub, err = curve25519.X25519(priv, curve25519.Basepoint)
+	if err != nil {
+		AnnihilateData(priv)
+		return nil, nil, err
+	}
+	return pub, priv, nil
+}
+
+func getPublicKey(priv []byte) ([]byte, error) {
+	if len(priv) != PrivateKeySize {
+		return nil, fmt.Errorf("wrong private key size %d", len(priv))
+	}
+	return curve25519.X25519(priv, curve25519.Basepoint)
+}
+
+// the shared secret is expanded to the size of the regular key, and bound to both public keys
+func deriveSharedKey(priv []byte, peer []byte, ephemeral []byte, recipient []byte) ([]byte, error) {
+	shared, err := curve25519.X25519(priv, peer)
+	if err != nil {
+		return nil, err
+	}
+	full := make([]byte, 0, len(shared)+len(ephemeral)+len(recipient))
+	full = append(full, shared...)
+	full = append(full, ephemeral...)
+	full = append(full, recipient...)
+	key := keccak.Digest(full, KdfKeySize)
+	AnnihilateData(shared)
+	AnnihilateData(full)
+	return key, nil
+}
+
+func EncryptToPublicKey(pub []byte, data []byte) ([]byte, error) {
+	if len(pub) != PublicKeySize {
+		return nil, fmt.Errorf("wrong public key size %d", len(pub))
+	}
+	ephemeral, ephemeralPriv, err := GenerateKeyPair()
+	if err != nil {
+		return nil, err
+	}
+	key, err := deriveSharedKey(ephemeralPriv, pub, ephemeral, pub)
+	AnnihilateData(ephemeralPriv)
+	if err != nil {
+		return nil, err
+	}
+	defer AnnihilateData(key)
+
+	hdr := NewHeader(ModePublicKey).Bytes()
+	data, _ = addPaddingAndSpacing(data, nil)
+	body, err := encrypt(key, data, hdr)
+	if err != nil {
+		return nil, err
+	}
+
+	res := make([]byte, 0, HeaderSize+PublicKeySize+len(body))
+	res = append(res, hdr...)
+	res = append(res, ephemeral...)
+	res = append(res, body...)
+	AnnihilateData(body)
+	return res, nil
+}
+
+// multi-recipient data is also accepted (see EncryptForRecipients).
+// don't forget to annihilate the private key!
+func DecryptWithPrivateKey(priv []byte, data []byte) (res []byte, spacing []byte, err error) {
+	if len(priv) != PrivateKeySize {
+		return nil, nil, fmt.Errorf("wrong private key size %d", len(priv))
+	}
+	h, err := ParseHeader(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	if h.Mode == ModeSlots {
+		return decryptWithSlots(priv, true, data)
+	}
+	if h.Mode != ModePublicKey {
+		return nil, nil, fmt.Errorf("wrong mode: expected %s, got %s", ModeName(ModePublicKey), ModeName(h.Mode))
+	}
+	if len(data) <= HeaderSize+PublicKeySize {
+		return nil, nil, errors.New("data is too small")
+	}
+
+	hdr := data[:HeaderSize]
+	ephemeral := data[HeaderSize : HeaderSize+PublicKeySize]
+	pub, err := getPublicKey(priv)
+	if err != nil {
+		return nil, nil, err
+	}
+	key, err := deriveSharedKey(priv, ephemeral, ephemeral, pub)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer AnnihilateData(key)
+	return decrypt(key, data[HeaderSize+PublicKeySize:], hdr)
+}
+
+func IsPublicKeyEncrypted(data []byte) bool {
+	h, err := ParseHeader(data)
+	return err == nil && h.Mode == ModePublicKey
+}
@@ -0,0 +1,84 @@
+package crutils
+
+import (
+	"bytes"
+	mrand "math/rand"
+	"testing"
+	"time"
+)
+
+func TestPublicKeyEncryption(t *testing.T) {
+	seed := time.Now().Unix()
+	mrand.Seed(seed)
+
+	pub, priv, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, priv2, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 4; i++ {
+		data := generateRandomBytes(t, i%2 == 0)
+		orig := make([]byte, len(data))
+		copy(orig, data)
+
+		encrypted, err := EncryptToPublicKey(pub, data)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !IsPublicKeyEncrypted(encrypted) {
+			t.Fatal("wrong header")
+		}
+
+		dup := make([]byte, len(encrypted))
+		copy(dup, encrypted)
+		if _, _, err = DecryptWithPrivateKey(priv2, dup); err == nil {
+			t.Fatal("decrypted with wrong private key")
+		}
+		copy(dup, encrypted)
+		if _, _, err = Decrypt(pub, dup); err == nil {
+			t.Fatal("decrypted without private key")
+		}
+		copy(dup, encrypted)
+		dup[HeaderSize+1]++ // modify ephemeral public key
+		if _, _, err = DecryptWithPrivateKey(priv, dup); err == nil {
+			t.Fatal("modified ephemeral key not detected")
+		}
+
+		decrypted, _, err := DecryptWithPrivateKey(priv, encrypted)
+		if err != nil {
+			t.Fatalf("round %d, seed %d: %s", i, seed, err)
+		}
+		if !bytes.Equal(decrypted, orig) {
+			t.Fatalf("decrypted != expected, round %d, seed %d", i, seed)
+		}
+	}
+}
+
+func TestGenerateKeyPair(t *testing.T) {
+	pub1, priv1, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub2, priv2, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pub1) != PublicKeySize || len(priv1) != PrivateKeySize {
+		t.Fatalf("wrong key size [%d, %d]", len(pub1), len(priv1))
+	}
+	if bytes.Equal(pub1, pub2) || bytes.Equal(priv1, priv2) {
+		t.Fatal("same keys generated twice")
+	}
+
+	data := []byte("some data")
+	if _, err = EncryptToPublicKey(pub1[1:], data); err == nil {
+		t.Fatal("wrong public key accepted")
+	}
+	if _, err = EncryptToPublicKey(make([]byte, PublicKeySize), data); err == nil {
+		t.Fatal("low order point accepted")
+	}
+}
@@ -0,0 +1,230 @@
+package crutils
+
+import (
+	"bufio"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"runtime"
+	"sync"
+
+	"github.com/gluk256/crypto/algo/rcx"
+)
+
+// Chunked quick mode: the same layers as in EncryptQuick (RC4, Keccak, AES-GCM), but the data is
+// split into independent chunks, which are processed in parallel on all CPU cores.
+// Each chunk has its own subkeys (see getChunkKey), and is authenticated separately;
+// the last chunk is explicitly marked as final, so that truncation and reordering are detected.
+//
+// layout: [header][salt][chunk_0]...[chunk_N], where the last chunk might be shorter (or even empty).
+// the header is mandatory: ModeQuick with non-zero chunk size.
+
+const QuickChunkSize = 1024 * 1024
+
+// the number of chunks processed in parallel
+func getBatchSize() int {
+	return runtime.NumCPU() * 2
+}
+
+func NewQuickChunkedHeader() *Header {
+	h := NewHeader(ModeQuick)
+	h.ChunkSize = QuickChunkSize
+	return h
+}
+
+func IsQuickChunked(data []byte) bool {
+	h, err := ParseHeader(data)
+	return err == nil && h.Mode == ModeQuick && h.ChunkSize != 0
+}
+
+func encryptQuickChunk(keyholder []byte, index uint64, final bool, data []byte) ([]byte, error) {
+	kr := getChunkKey(getRcxKey(keyholder), index)
+	k1 := getChunkKey(getKey1(keyholder), index)
+	defer AnnihilateData(kr)
+	defer AnnihilateData(k1)
+
+	rcx.EncryptInplaceRC4(kr, data)
+	EncryptInplaceKeccak(k1, data)
+	salt := getChunkAesSalt(getAesSalt(keyholder), index)
+	return encryptAES(getAesKey(keyholder), salt, data, getChunkAdditionalData(final))
+}
+
+func decryptQuickChunk(keyholder []byte, index uint64, final bool, data []byte) ([]byte, error) {
+	kr := getChunkKey(getRcxKey(keyholder), index)
+	k1 := getChunkKey(getKey1(keyholder), index)
+	defer AnnihilateData(kr)
+	defer AnnihilateData(k1)
+
+	salt := getChunkAesSalt(getAesSalt(keyholder), index)
+	res, err := decryptAES(getAesKey(keyholder), salt, data, getChunkAdditionalData(final))
+	if err != nil {
+		return nil, err
+	}
+	EncryptInplaceKeccak(k1, res)
+	rcx.EncryptInplaceRC4(kr, res)
+	return res, nil
+}
+
+// reads up to n chunks, final is true if there is no more data after them
+func readChunks(r *bufio.Reader, size int, n int) (chunks [][]byte, final bool, err error) {
+	for len(chunks) < n {
+		buf := make([]byte, size)
+		sz, err := io.ReadFull(r, buf)
+		if err == io.EOF || err == io.ErrUnexpectedEOF {
+			return append(chunks, buf[:sz]), true, nil
+		} else if err != nil {
+			return nil, false, err
+		}
+		chunks = append(chunks, buf)
+		if _, err = r.Peek(1); err == io.EOF {
+			return chunks, true, nil
+		}
+	}
+	return chunks, false, nil
+}
+
+type chunkFunc func(keyholder []byte, index uint64, final bool, data []byte) ([]byte, error)
+
+// processes the batch of chunks by the pool of workers, the order of results is preserved
+func processChunks(f chunkFunc, keyholder []byte, first uint64, final bool, chunks [][]byte) ([][]byte, error) {
+	res := make([][]byte, len(chunks))
+	errs := make([]error, len(chunks))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < runtime.NumCPU(); w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				last := final && i == len(chunks)-1
+				res[i], errs[i] = f(keyholder, first+uint64(i), last, chunks[i])
+			}
+		}()
+	}
+	for i := range chunks {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	for i, err := range errs {
+		if err != nil {
+			return nil, fmt.Errorf("failed to process chunk %d: %s", first+uint64(i), err.Error())
+		}
+	}
+	return res, nil
+}
+
+// reads the input chunk by chunk, processes in parallel, and writes the results in the original order
+func processQuickStream(f chunkFunc, keyholder []byte, chunkSize int, r io.Reader, w io.Writer) error {
+	br := bufio.NewReader(r)
+	var index uint64
+	for {
+		chunks, final, err := readChunks(br, chunkSize, getBatchSize())
+		if err != nil {
+			return err
+		}
+		res, err := processChunks(f, keyholder, index, final, chunks)
+		for _, c := range res {
+			if err == nil {
+				_, err = w.Write(c)
+			}
+			AnnihilateData(c) // in case of decryption, it also destroys the chunk
+		}
+		for _, c := range chunks {
+			AnnihilateData(c[:cap(c)])
+		}
+		if err != nil || final {
+			return err
+		}
+		index += uint64(len(chunks))
+	}
+}
+
+// h might be nil (default header). the header is written to the output.
+// don't forget to annihilate the key!
+func EncryptQuickStream(h *Header, key []byte, r io.Reader, w io.Writer) error {
+	if h == nil {
+		h = NewQuickChunkedHeader()
+	}
+	if h.Mode != ModeQuick || h.ChunkSize == 0 {
+		return errors.New("wrong header: chunked quick mode expected")
+	}
+	if err := h.validate(); err != nil {
+		return err
+	}
+	hdr := h.Bytes()
+	if _, err := w.Write(hdr); err != nil {
+		return err
+	}
+	return encryptQuickChunked(h, hdr, key, r, w)
+}
+
+// the header must be already written
+func encryptQuickChunked(h *Header, hdr []byte, key []byte, r io.Reader, w io.Writer) error {
+	salt, err := GenerateSalt()
+	if err != nil {
+		return err
+	}
+	if _, err = w.Write(salt); err != nil {
+		return err
+	}
+	kh, err := generateKeysWithHeader(key, salt, hdr)
+	if err != nil {
+		return err
+	}
+	defer kh.Destroy()
+	keyholder := kh.Bytes()
+	return processQuickStream(encryptQuickChunk, keyholder, int(h.ChunkSize), r, w)
+}
+
+// the decrypted data is written chunk by chunk, so in case of error the output might be incomplete.
+// don't forget to annihilate the key!
+func DecryptQuickStream(key []byte, r io.Reader, w io.Writer) error {
+	hdr := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return fmt.Errorf("failed to read header: %s", err.Error())
+	}
+	if !IsQuickChunked(hdr) {
+		return errors.New("wrong header: chunked quick mode expected")
+	}
+	h, _ := ParseHeader(hdr)
+	return decryptQuickChunked(h, hdr, key, r, w)
+}
+
+// the header must be already read, and the reader must point to the salt
+func decryptQuickChunked(h *Header, hdr []byte, key []byte, r io.Reader, w io.Writer) error {
+	salt := make([]byte, SaltSize)
+	if _, err := io.ReadFull(r, salt); err != nil {
+		return fmt.Errorf("failed to read salt: %s", err.Error())
+	}
+	kh, err := generateKeysWithHeader(key, salt, hdr)
+	if err != nil {
+		return err
+	}
+	defer kh.Destroy()
+	keyholder := kh.Bytes()
+	return processQuickStream(decryptQuickChunk, keyholder, int(h.ChunkSize)+AesEncryptedSizeDiff, r, w)
+}
+
+// in-memory version of the chunked quick mode (see EncryptWithHeader)
+func encryptQuickChunkedData(h *Header, hdr []byte, key []byte, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(data) + len(data)/int(h.ChunkSize)*AesEncryptedSizeDiff + 1024)
+	buf.Write(hdr)
+	err := encryptQuickChunked(h, hdr, key, bytes.NewReader(data), &buf)
+	AnnihilateData(data)
+	return buf.Bytes(), err
+}
+
+func decryptQuickChunkedData(h *Header, hdr []byte, key []byte, body []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	buf.Grow(len(body))
+	err := decryptQuickChunked(h, hdr, key, bytes.NewReader(body), &buf)
+	if err != nil {
+		AnnihilateData(buf.Bytes())
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
@@ -0,0 +1,167 @@
+package crutils
+
+import (
+	"bytes"
+	mrand "math/rand"
+	"testing"
+	"time"
+
+	"github.com/gluk256/crypto/algo/primitives"
+)
+
+const testQuickChunkSize = 1000
+
+func encryptQuickStream(t *testing.T, key []byte, data []byte) []byte {
+	h := NewQuickChunkedHeader()
+	h.ChunkSize = testQuickChunkSize
+	var buf bytes.Buffer
+	if err := EncryptQuickStream(h, key, bytes.NewReader(data), &buf); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decryptQuickStream(key []byte, data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	err := DecryptQuickStream(key, bytes.NewReader(data), &buf)
+	return buf.Bytes(), err
+}
+
+func TestQuickChunked(t *testing.T) {
+	seed := time.Now().Unix()
+	mrand.Seed(seed)
+
+	const chunk = testQuickChunkSize
+	key := generateRandomBytes(t, false)
+	sizes := []int{0, 1, 77, chunk - 1, chunk, chunk + 1, chunk*getBatchSize() + 1, chunk*getBatchSize()*3 + 123}
+	for _, sz := range sizes {
+		data := make([]byte, sz)
+		mrand.Read(data)
+		encrypted := encryptQuickStream(t, key, data)
+		if !IsQuickChunked(encrypted) {
+			t.Fatal("wrong header")
+		}
+		chunks := sz / chunk
+		if sz%chunk != 0 || sz == 0 {
+			chunks++
+		}
+		expected := streamPrefix + sz + chunks*AesEncryptedSizeDiff
+		if len(encrypted) != expected {
+			t.Fatalf("wrong size [%d vs. %d], data size %d", len(encrypted), expected, sz)
+		}
+		if sz > 0 && !primitives.IsDeepNotEqual(data, encrypted[streamPrefix:], sz) {
+			t.Fatalf("deep non-equal test failed, size %d, seed %d", sz, seed)
+		}
+
+		dup := make([]byte, len(encrypted))
+		copy(dup, encrypted)
+		decrypted, err := decryptQuickStream(key, dup)
+		if err != nil {
+			t.Fatalf("decryption failed, size %d, seed %d: %s", sz, seed, err)
+		}
+		if !bytes.Equal(decrypted, data) {
+			t.Fatalf("decrypted != expected, size %d, seed %d", sz, seed)
+		}
+
+		decrypted, err = DecryptQuick(key, encrypted)
+		if err != nil {
+			t.Fatalf("in-memory decryption failed, size %d, seed %d: %s", sz, seed, err)
+		}
+		if !bytes.Equal(decrypted, data) {
+			t.Fatalf("in-memory decrypted != expected, size %d, seed %d", sz, seed)
+		}
+	}
+}
+
+func TestQuickChunkedTampering(t *testing.T) {
+	seed := time.Now().Unix()
+	mrand.Seed(seed)
+
+	const encryptedChunk = testQuickChunkSize + AesEncryptedSizeDiff
+	key := generateRandomBytes(t, false)
+	data := make([]byte, testQuickChunkSize*getBatchSize()*2+100)
+	mrand.Read(data)
+	encrypted := encryptQuickStream(t, key, data)
+
+	wrongKey := make([]byte, len(key))
+	copy(wrongKey, key)
+	wrongKey[0]++
+	if _, err := decryptQuickStream(wrongKey, encrypted); err == nil {
+		t.Fatal("decrypted with wrong key")
+	}
+
+	modified := make([]byte, len(encrypted))
+	copy(modified, encrypted)
+	modified[streamPrefix+encryptedChunk+mrand.Intn(encryptedChunk)]++
+	if _, err := decryptQuickStream(key, modified); err == nil {
+		t.Fatalf("decrypted modified data, seed %d", seed)
+	}
+
+	// truncated at the chunk boundary, including the batch boundary
+	for _, n := range []int{1, 2, getBatchSize()} {
+		truncated := encrypted[:streamPrefix+encryptedChunk*n]
+		if _, err := decryptQuickStream(key, truncated); err == nil {
+			t.Fatalf("truncation not detected, %d chunks", n)
+		}
+	}
+
+	// the first two chunks swapped
+	swapped := make([]byte, 0, len(encrypted))
+	swapped = append(swapped, encrypted[:streamPrefix]...)
+	swapped = append(swapped, encrypted[streamPrefix+encryptedChunk:streamPrefix+encryptedChunk*2]...)
+	swapped = append(swapped, encrypted[streamPrefix:streamPrefix+encryptedChunk]...)
+	swapped = append(swapped, encrypted[streamPrefix+encryptedChunk*2:]...)
+	if _, err := decryptQuickStream(key, swapped); err == nil {
+		t.Fatal("reordering not detected")
+	}
+
+	// chunk size in the header is authenticated
+	modified = make([]byte, len(encrypted))
+	copy(modified, encrypted)
+	modified[HeaderSize-4]++
+	if _, err := decryptQuickStream(key, modified); err == nil {
+		t.Fatal("modified header not detected")
+	}
+
+	// make sure the original is still intact
+	decrypted, err := decryptQuickStream(key, encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, data) {
+		t.Fatalf("decrypted != expected, seed %d", seed)
+	}
+}
+
+func TestQuickChunkedWithHeader(t *testing.T) {
+	seed := time.Now().Unix()
+	mrand.Seed(seed)
+
+	key := generateRandomBytes(t, false)
+	data := generateRandomBytes(t, true)
+	orig := make([]byte, len(data))
+	copy(orig, data)
+
+	h := NewQuickChunkedHeader()
+	h.SetKdf(KdfScrypt)
+	h.KdfParams = testKdfParams[KdfScrypt]
+	encrypted, err := EncryptWithHeader(h, key, data, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	decrypted, _, err := Decrypt(key, encrypted)
+	if err != nil {
+		t.Fatalf("seed %d: %s", seed, err)
+	}
+	if !bytes.Equal(decrypted, orig) {
+		t.Fatalf("decrypted != expected, seed %d", seed)
+	}
+
+	h.Mode = ModeMain
+	if _, err = EncryptWithHeader(h, key, orig, nil); err == nil {
+		t.Fatal("chunk size accepted in main mode")
+	}
+	if err = EncryptQuickStream(NewHeader(ModeQuick), key, bytes.NewReader(orig), &bytes.Buffer{}); err == nil {
+		t.Fatal("quick stream without chunk size")
+	}
+}
@@ -0,0 +1,65 @@
+package crutils
+
+// SecureBuffer holds the key material outside of the garbage collected heap.
+// Where supported (see securebuf_linux.go), the memory is mmap'd and locked (never swapped to disk),
+// excluded from core dumps, and surrounded by guard pages (any overflow crashes immediately).
+// Otherwise the buffer falls back to the ordinary heap memory.
+// Don't forget to Destroy() the buffer!
+type SecureBuffer struct {
+	data   []byte // points into mem
+	mem    []byte // the whole mapping, including the guard pages (nil in case of fallback)
+	locked bool
+}
+
+// the buffer is never nil, even if the secure allocation fails
+func NewSecureBuffer(size int) *SecureBuffer {
+	if size < 0 {
+		size = 0
+	}
+	b := &SecureBuffer{}
+	if err := b.allocate(size); err != nil {
+		b.data = make([]byte, size)
+		b.mem = nil
+		b.locked = false
+	}
+	return b
+}
+
+// copies the data into the new buffer, and annihilates the source
+func NewSecureBufferFrom(src []byte) *SecureBuffer {
+	b := NewSecureBuffer(len(src))
+	copy(b.data, src)
+	AnnihilateData(src)
+	return b
+}
+
+// returns nil if the buffer is destroyed
+func (b *SecureBuffer) Bytes() []byte {
+	if b == nil {
+		return nil
+	}
+	return b.data
+}
+
+func (b *SecureBuffer) Len() int {
+	return len(b.Bytes())
+}
+
+// returns true if the memory is protected from swapping
+func (b *SecureBuffer) IsLocked() bool {
+	return b != nil && b.locked
+}
+
+// wipes the data and releases the memory; it is safe to call Destroy() several times
+func (b *SecureBuffer) Destroy() {
+	if b == nil {
+		return
+	}
+	AnnihilateData(b.data)
+	if b.mem != nil {
+		b.free()
+	}
+	b.data = nil
+	b.mem = nil
+	b.locked = false
+}
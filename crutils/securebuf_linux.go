@@ -0,0 +1,49 @@
+//go:build linux
+
+package crutils
+
+import (
+	"os"
+	"syscall"
+)
+
+const madvDontDump = 0x10 // MADV_DONTDUMP is not defined in syscall package
+
+// memory layout: [guard page][data pages][guard page].
+// the data is aligned to the end of the data pages, so that overflow hits the trailing guard page.
+func (b *SecureBuffer) allocate(size int) error {
+	page := os.Getpagesize()
+	inner := (size + page - 1) / page * page
+	if inner == 0 {
+		inner = page
+	}
+	mem, err := syscall.Mmap(-1, 0, inner+page*2, syscall.PROT_READ|syscall.PROT_WRITE, syscall.MAP_PRIVATE|syscall.MAP_ANON)
+	if err != nil {
+		return err
+	}
+	err = syscall.Mprotect(mem[:page], syscall.PROT_NONE)
+	if err == nil {
+		err = syscall.Mprotect(mem[page+inner:], syscall.PROT_NONE)
+	}
+	if err != nil {
+		syscall.Munmap(mem)
+		return err
+	}
+
+	data := mem[page : page+inner]
+	// mlock might fail because of RLIMIT_MEMLOCK, in which case the buffer is still usable
+	b.locked = syscall.Mlock(data) == nil
+	syscall.Madvise(data, madvDontDump)
+	b.mem = mem
+	b.data = data[inner-size:]
+	return nil
+}
+
+func (b *SecureBuffer) free() {
+	page := os.Getpagesize()
+	data := b.mem[page : len(b.mem)-page]
+	if b.locked {
+		syscall.Munlock(data)
+	}
+	syscall.Munmap(b.mem)
+}
@@ -0,0 +1,11 @@
+//go:build !linux
+
+package crutils
+
+import "errors"
+
+func (b *SecureBuffer) allocate(size int) error {
+	return errors.New("secure memory is not supported on this platform")
+}
+
+func (b *SecureBuffer) free() {}
@@ -0,0 +1,69 @@
+package crutils
+
+import (
+	"bytes"
+	mrand "math/rand"
+	"testing"
+	"time"
+)
+
+func TestSecureBuffer(t *testing.T) {
+	seed := time.Now().Unix()
+	mrand.Seed(seed)
+
+	for _, sz := range []int{0, 1, 4095, 4096, 4097, 1024*64 + mrand.Intn(1024)} {
+		b := NewSecureBuffer(sz)
+		if b.Len() != sz || len(b.Bytes()) != sz {
+			t.Fatalf("wrong size [%d vs. %d], seed = %d", b.Len(), sz, seed)
+		}
+		if sz == 0 {
+			b.Destroy()
+			continue
+		}
+		data := b.Bytes()
+		Randomize(data)
+		data[0] = 1 // the whole range must be accessible
+		data[sz-1] = 2
+		if data[sz-1] != 2 {
+			t.Fatalf("failed to write, seed = %d", seed)
+		}
+		b.Destroy()
+		if b.Bytes() != nil || b.Len() != 0 || b.IsLocked() {
+			t.Fatalf("failed to destroy, seed = %d", seed)
+		}
+		b.Destroy() // must be safe
+	}
+
+	var empty *SecureBuffer
+	if empty.Bytes() != nil || empty.Len() != 0 {
+		t.Fatal("nil buffer is not empty")
+	}
+	empty.Destroy()
+}
+
+func TestSecureBufferFrom(t *testing.T) {
+	src := make([]byte, 300)
+	Randomize(src)
+	orig := make([]byte, len(src))
+	copy(orig, src)
+	b := NewSecureBufferFrom(src)
+	defer b.Destroy()
+	if !bytes.Equal(b.Bytes(), orig) {
+		t.Fatal("failed to copy")
+	}
+	if bytes.Equal(src, orig) {
+		t.Fatal("source is not annihilated")
+	}
+}
+
+func TestSecureKeyholder(t *testing.T) {
+	key := []byte("7ba46c7d9b4fec50a6e1a9c1d8a6e1b4")
+	salt := make([]byte, SaltSize)
+	Randomize(salt)
+	expected := GenerateKeys(key, salt)
+	kh := generateKeyholder(key, salt)
+	if !bytes.Equal(kh.Bytes(), expected) {
+		t.Fatal("keyholder mismatch")
+	}
+	kh.Destroy()
+}
@@ -0,0 +1,128 @@
+package crutils
+
+import (
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+)
+
+// Shredding: the file content is overwritten with random data several times (synced after each pass),
+// then the file is renamed to random name (to hide the original name), truncated and removed.
+// Symlinks are removed, but never followed.
+// Please note that on SSD, journaling and copy-on-write file systems the old data might survive anyway.
+
+const (
+	DefaultShredPasses = 3
+	shredBufferSize    = 64 * 1024
+)
+
+func randomName(dir string) string {
+	b := make([]byte, 12)
+	Randomize(b)
+	return filepath.Join(dir, hex.EncodeToString(b))
+}
+
+func overwriteFile(f *os.File, size int64, passes int) error {
+	buf := make([]byte, shredBufferSize)
+	for p := 0; p < passes; p++ {
+		if _, err := f.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		for done := int64(0); done < size; {
+			n := shredBufferSize
+			if size-done < int64(n) {
+				n = int(size - done)
+			}
+			Randomize(buf[:n])
+			if _, err := f.Write(buf[:n]); err != nil {
+				return err
+			}
+			done += int64(n)
+		}
+		if err := f.Sync(); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// renames the file (or empty directory) to random name, and removes it
+func renameAndRemove(name string) error {
+	tmp := randomName(filepath.Dir(name))
+	if err := os.Rename(name, tmp); err != nil {
+		return err
+	}
+	return os.Remove(tmp)
+}
+
+func ShredFile(name string, passes int) error {
+	if passes < 1 {
+		return fmt.Errorf("wrong number of passes %d", passes)
+	}
+	info, err := os.Lstat(name)
+	if err != nil {
+		return err
+	}
+	if info.Mode()&os.ModeSymlink != 0 {
+		return os.Remove(name)
+	}
+	if !info.Mode().IsRegular() {
+		return fmt.Errorf("[%s] is not a regular file", name)
+	}
+
+	f, err := os.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		return err
+	}
+	err = overwriteFile(f, info.Size(), passes)
+	if err == nil {
+		err = f.Truncate(0)
+	}
+	if errClose := f.Close(); err == nil {
+		err = errClose
+	}
+	if err != nil {
+		return err
+	}
+	return renameAndRemove(name)
+}
+
+// returns all the files and directories in the tree, the directories go after their content
+func ListTree(dir string) (files []string, dirs []string, err error) {
+	err = filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		if info.IsDir() {
+			dirs = append(dirs, path)
+		} else {
+			files = append(files, path)
+		}
+		return nil
+	})
+	for i, j := 0, len(dirs)-1; i < j; i, j = i+1, j-1 {
+		dirs[i], dirs[j] = dirs[j], dirs[i]
+	}
+	return files, dirs, err
+}
+
+// shreds all the files in the tree, and then removes the directories
+func ShredDir(dir string, passes int) error {
+	files, dirs, err := ListTree(dir)
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		if err = ShredFile(f, passes); err != nil {
+			return fmt.Errorf("failed to shred [%s]: %s", f, err.Error())
+		}
+	}
+	for _, d := range dirs {
+		if err = renameAndRemove(d); err != nil {
+			return fmt.Errorf("failed to remove [%s]: %s", d, err.Error())
+		}
+	}
+	return nil
+}
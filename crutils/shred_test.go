@@ -0,0 +1,86 @@
+package crutils
+
+import (
+	"bytes"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestShredFile(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xcry-shred")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	name := filepath.Join(dir, "secret")
+	data := make([]byte, shredBufferSize*2+17)
+	if err = ioutil.WriteFile(name, data, 0600); err != nil {
+		t.Fatal(err)
+	}
+	f, err := os.OpenFile(name, os.O_WRONLY, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = overwriteFile(f, int64(len(data)), 1); err != nil {
+		t.Fatal(err)
+	}
+	f.Close()
+	overwritten, err := ioutil.ReadFile(name)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(overwritten) != len(data) || bytes.Equal(overwritten, data) {
+		t.Fatal("file is not overwritten")
+	}
+
+	if err = ShredFile(name, DefaultShredPasses); err != nil {
+		t.Fatal(err)
+	}
+	files, _ := ioutil.ReadDir(dir)
+	if len(files) != 0 {
+		t.Fatalf("%d files remain", len(files))
+	}
+	if ShredFile(name, 1) == nil {
+		t.Fatal("non-existing file shredded")
+	}
+	if ShredFile(dir, 1) == nil {
+		t.Fatal("directory shredded as file")
+	}
+}
+
+func TestShredDir(t *testing.T) {
+	dir, err := ioutil.TempDir("", "xcry-shred")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	outside := filepath.Join(dir, "outside")
+	tree := filepath.Join(dir, "tree")
+	os.MkdirAll(filepath.Join(tree, "a", "b"), 0700)
+	ioutil.WriteFile(outside, []byte("must survive"), 0600)
+	ioutil.WriteFile(filepath.Join(tree, "x"), []byte("secret x"), 0600)
+	ioutil.WriteFile(filepath.Join(tree, "a", "b", "y"), []byte("secret y"), 0600)
+	os.Symlink(outside, filepath.Join(tree, "a", "link"))
+
+	files, dirs, err := ListTree(tree)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 3 || len(dirs) != 3 || dirs[len(dirs)-1] != tree {
+		t.Fatalf("wrong tree: %v %v", files, dirs)
+	}
+
+	if err = ShredDir(tree, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Lstat(tree); !os.IsNotExist(err) {
+		t.Fatal("directory remains")
+	}
+	if b, err := ioutil.ReadFile(outside); err != nil || string(b) != "must survive" {
+		t.Fatal("symlink target is destroyed")
+	}
+}
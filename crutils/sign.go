@@ -0,0 +1,84 @@
+package crutils
+
+import (
+	"crypto/ed25519"
+	"errors"
+	"fmt"
+)
+
+// Ed25519 signatures. The detached signature proves who produced the data (e.g. encrypted blob, or hash).
+// In case of sign-then-encrypt, the signature is appended to the data before encryption,
+// so that only the recipient can see who signed it.
+
+const (
+	SignPublicKeySize  = ed25519.PublicKeySize
+	SignPrivateKeySize = ed25519.PrivateKeySize
+	SignatureSize      = ed25519.SignatureSize
+)
+
+// don't forget to annihilate the private key!
+func GenerateSigningKeyPair() (pub []byte, priv []byte, err error) {
+	seed := make([]byte, ed25519.SeedSize)
+	defer AnnihilateData(seed)
+	if err = StochasticRand(seed); err != nil {
+		return nil, nil, err
+	}
+	priv = ed25519.NewKeyFromSeed(seed)
+	pub = make([]byte, SignPublicKeySize)
+	copy(pub, priv[ed25519.SeedSize:])
+	return pub, priv, nil
+}
+
+func Sign(priv []byte, data []byte) ([]byte, error) {
+	if len(priv) != SignPrivateKeySize {
+		return nil, fmt.Errorf("wrong private key size %d", len(priv))
+	}
+	return ed25519.Sign(priv, data), nil
+}
+
+func Verify(pub []byte, data []byte, sig []byte) error {
+	if len(pub) != SignPublicKeySize {
+		return fmt.Errorf("wrong public key size %d", len(pub))
+	}
+	if len(sig) != SignatureSize {
+		return fmt.Errorf("wrong signature size %d", len(sig))
+	}
+	if !ed25519.Verify(pub, data, sig) {
+		return errors.New("signature is not valid")
+	}
+	return nil
+}
+
+// the data is signed, then the data with signature is encrypted in the main mode.
+// don't forget to annihilate the keys!
+func SignAndEncrypt(priv []byte, key []byte, data []byte) ([]byte, error) {
+	sig, err := Sign(priv, data)
+	if err != nil {
+		return nil, err
+	}
+	signed := make([]byte, 0, len(data)+SignatureSize)
+	signed = append(signed, data...)
+	signed = append(signed, sig...)
+	AnnihilateData(data)
+	return Encrypt(key, signed)
+}
+
+// returns the decrypted data only if the signature is valid.
+// don't forget to annihilate the key!
+func DecryptAndVerify(pub []byte, key []byte, data []byte) ([]byte, error) {
+	decrypted, _, err := Decrypt(key, data)
+	if err != nil {
+		return nil, err
+	}
+	if len(decrypted) < SignatureSize {
+		AnnihilateData(decrypted)
+		return nil, errors.New("signature is missing")
+	}
+	res := decrypted[:len(decrypted)-SignatureSize]
+	sig := decrypted[len(decrypted)-SignatureSize:]
+	if err = Verify(pub, res, sig); err != nil {
+		AnnihilateData(decrypted)
+		return nil, err
+	}
+	return res, nil
+}
@@ -0,0 +1,111 @@
+package crutils
+
+import (
+	"bytes"
+	"encoding/hex"
+	mrand "math/rand"
+	"testing"
+	"time"
+)
+
+func TestSignatureVector(t *testing.T) {
+	// RFC 8032, test 1
+	seed := "9d61b19deffd5a60ba844af492ec2cc44449c5697b326919703bac031cae7f60"
+	pub := "d75a980182b10ab7d54bfed3c964073a0ee172f3daa62325af021a68f707511a"
+	expected := "e5564300c360ac729086e2cc806e828a84877f1eb8e5d974d873e065224901555fb8821590a33bacc61e39701cf9b46bd25bf5f0595bbe24655141438e7a100b"
+
+	priv, _ := hex.DecodeString(seed + pub)
+	p, _ := hex.DecodeString(pub)
+	sig, err := Sign(priv, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if hex.EncodeToString(sig) != expected {
+		t.Fatalf("wrong signature %x", sig)
+	}
+	if err = Verify(p, nil, sig); err != nil {
+		t.Fatal(err)
+	}
+	if err = Verify(p, []byte{0}, sig); err == nil {
+		t.Fatal("wrong data accepted")
+	}
+}
+
+func TestSignature(t *testing.T) {
+	seed := time.Now().Unix()
+	mrand.Seed(seed)
+
+	pub, priv, err := GenerateSigningKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub2, _, err := GenerateSigningKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(pub) != SignPublicKeySize || len(priv) != SignPrivateKeySize {
+		t.Fatalf("wrong key size [%d, %d]", len(pub), len(priv))
+	}
+	if bytes.Equal(pub, pub2) {
+		t.Fatal("same keys generated twice")
+	}
+
+	data := generateRandomBytes(t, false)
+	sig, err := Sign(priv, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err = Verify(pub, data, sig); err != nil {
+		t.Fatalf("seed %d: %s", seed, err)
+	}
+	if err = Verify(pub2, data, sig); err == nil {
+		t.Fatal("verified with wrong public key")
+	}
+	data[mrand.Intn(len(data))]++
+	if err = Verify(pub, data, sig); err == nil {
+		t.Fatal("modified data not detected")
+	}
+	if _, err = Sign(priv[1:], data); err == nil {
+		t.Fatal("wrong private key accepted")
+	}
+	if err = Verify(pub, data, sig[1:]); err == nil {
+		t.Fatal("wrong signature accepted")
+	}
+}
+
+func TestSignAndEncrypt(t *testing.T) {
+	seed := time.Now().Unix()
+	mrand.Seed(seed)
+
+	pub, priv, err := GenerateSigningKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	pub2, _, err := GenerateSigningKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	key := generateRandomBytes(t, false)
+	data := generateRandomBytes(t, true)
+	orig := make([]byte, len(data))
+	copy(orig, data)
+
+	encrypted, err := SignAndEncrypt(priv, key, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	dup := make([]byte, len(encrypted))
+	copy(dup, encrypted)
+	if _, err = DecryptAndVerify(pub2, key, dup); err == nil {
+		t.Fatal("verified with wrong public key")
+	}
+
+	decrypted, err := DecryptAndVerify(pub, key, encrypted)
+	if err != nil {
+		t.Fatalf("seed %d: %s", seed, err)
+	}
+	if !bytes.Equal(decrypted, orig) {
+		t.Fatalf("decrypted != expected, seed %d", seed)
+	}
+}
@@ -0,0 +1,320 @@
+package crutils
+
+import (
+	"crypto/subtle"
+	"errors"
+	"fmt"
+
+	"github.com/gluk256/crypto/algo/keccak"
+)
+
+// Multi-recipient encryption: the data is encrypted with random file key, which is wrapped
+// into several slots, one per recipient. Each slot is opened either by password or by private key.
+// Adding or removing a recipient only rewraps the slots, the bulk data remains untouched.
+// Please note: removing a slot does not revoke the access, if the recipient already knows the file key.
+//
+// The password slots are bound to the header, and processed with the KDF specified in the header.
+// The bulk data and the public key slots do not need KDF, since their keys are random.
+// The bulk data key is derived from the file key and the header, so the header can not be modified.
+// The slot table is authenticated by the MAC (keyed with the file key), so the slots can not be removed
+// or reordered without the key of one of the slots, while the bulk data is never re-encrypted.
+//
+// layout: [header][number_of_slots][slot_0]...[slot_N][mac][encrypted_data][salt]
+// slot layout: [type][ephemeral_public_key][wrapped_file_key]
+
+const (
+	SlotPassword = iota + 1
+	SlotPublicKey
+)
+
+const (
+	FileKeySize    = KdfKeySize
+	MaxSlots       = 255
+	wrappedKeySize = FileKeySize + AesEncryptedSizeDiff + SaltSize
+	SlotSize       = 1 + PublicKeySize + wrappedKeySize
+	slotsMacSize   = 32
+
+	slotsMacCustomization = "xcry slots mac"
+	slotsKeyCustomization = "xcry slots data key"
+)
+
+// either password (already digested, e.g. by common.GetPassword) or public key must be set
+type Recipient struct {
+	Password  []byte
+	PublicKey []byte
+}
+
+type slotsContainer struct {
+	header []byte
+	slots  [][]byte
+	mac    []byte
+	body   []byte
+}
+
+func IsMultiRecipient(data []byte) bool {
+	h, err := ParseHeader(data)
+	return err == nil && h.Mode == ModeSlots
+}
+
+func parseSlots(data []byte) (*slotsContainer, error) {
+	h, err := ParseHeader(data)
+	if err != nil {
+		return nil, err
+	}
+	if h.Mode != ModeSlots {
+		return nil, fmt.Errorf("wrong mode: expected %s, got %s", ModeName(ModeSlots), ModeName(h.Mode))
+	}
+	if len(data) <= HeaderSize {
+		return nil, errors.New("number of slots is missing")
+	}
+
+	n := int(data[HeaderSize])
+	beg := HeaderSize + 1
+	end := beg + n*SlotSize
+	if n == 0 || len(data) <= end+slotsMacSize+SaltSize {
+		return nil, fmt.Errorf("data is too small for %d slots [%d bytes]", n, len(data))
+	}
+
+	c := &slotsContainer{header: data[:HeaderSize], mac: data[end : end+slotsMacSize], body: data[end+slotsMacSize:]}
+	for i := beg; i < end; i += SlotSize {
+		c.slots = append(c.slots, data[i:i+SlotSize])
+	}
+	return c, nil
+}
+
+func (c *slotsContainer) serializeTable() []byte {
+	res := make([]byte, 0, HeaderSize+1+len(c.slots)*SlotSize+slotsMacSize+len(c.body))
+	res = append(res, c.header...)
+	res = append(res, byte(len(c.slots)))
+	for _, s := range c.slots {
+		res = append(res, s...)
+	}
+	return res
+}
+
+func (c *slotsContainer) serialize() []byte {
+	res := c.serializeTable()
+	res = append(res, c.mac...)
+	return append(res, c.body...)
+}
+
+// authenticates the header and all the slots (including their number and order)
+func (c *slotsContainer) computeMac(fileKey []byte) []byte {
+	return keccak.Kmac256(fileKey, c.serializeTable(), []byte(slotsMacCustomization), slotsMacSize)
+}
+
+func (c *slotsContainer) checkMac(fileKey []byte) error {
+	if subtle.ConstantTimeCompare(c.mac, c.computeMac(fileKey)) != 1 {
+		return errors.New("the slots or the header are modified")
+	}
+	return nil
+}
+
+// the key of the bulk data depends on the header, which is thus authenticated by the data.
+// don't forget to annihilate the result!
+func getDataKey(fileKey []byte, header []byte) []byte {
+	return keccak.Kmac256(fileKey, header, []byte(slotsKeyCustomization), FileKeySize)
+}
+
+func wrapFileKey(fileKey []byte, r Recipient, header []byte) ([]byte, error) {
+	var err error
+	var key []byte
+	slot := make([]byte, 1+PublicKeySize, SlotSize)
+	k := make([]byte, FileKeySize)
+	copy(k, fileKey)
+
+	if len(r.PublicKey) != 0 {
+		if len(r.PublicKey) != PublicKeySize {
+			return nil, fmt.Errorf("wrong public key size %d", len(r.PublicKey))
+		}
+		ephemeral, ephemeralPriv, err := GenerateKeyPair()
+		if err != nil {
+			return nil, err
+		}
+		key, err = deriveSharedKey(ephemeralPriv, r.PublicKey, ephemeral, r.PublicKey)
+		AnnihilateData(ephemeralPriv)
+		if err != nil {
+			return nil, err
+		}
+		defer AnnihilateData(key)
+		slot[0] = SlotPublicKey
+		copy(slot[1:], ephemeral)
+		header = nil
+	} else if len(r.Password) != 0 {
+		key = r.Password
+		slot[0] = SlotPassword
+		Randomize(slot[1:])
+	} else {
+		return nil, errors.New("recipient without password and public key")
+	}
+
+	wrapped, err := encryptQuick(key, k, header)
+	if err != nil {
+		return nil, err
+	}
+	return append(slot, wrapped...), nil
+}
+
+// the key is either password (already digested), or private key
+func unwrapFileKey(slot []byte, key []byte, private bool, header []byte) ([]byte, error) {
+	wrapped := make([]byte, wrappedKeySize)
+	copy(wrapped, slot[1+PublicKeySize:])
+
+	if private {
+		if slot[0] != SlotPublicKey {
+			return nil, errors.New("wrong slot type")
+		}
+		ephemeral := slot[1 : 1+PublicKeySize]
+		pub, err := getPublicKey(key)
+		if err != nil {
+			return nil, err
+		}
+		shared, err := deriveSharedKey(key, ephemeral, ephemeral, pub)
+		if err != nil {
+			return nil, err
+		}
+		defer AnnihilateData(shared)
+		return decryptQuick(shared, wrapped, nil)
+	}
+
+	if slot[0] != SlotPassword {
+		return nil, errors.New("wrong slot type")
+	}
+	return decryptQuick(key, wrapped, header)
+}
+
+// tries all the slots of appropriate type, and then verifies the slot table.
+// don't forget to annihilate the result!
+func (c *slotsContainer) findFileKey(key []byte, private bool) ([]byte, error) {
+	for _, s := range c.slots {
+		fileKey, err := unwrapFileKey(s, key, private, c.header)
+		if err == nil {
+			if err = c.checkMac(fileKey); err != nil {
+				AnnihilateData(fileKey)
+				return nil, err
+			}
+			return fileKey, nil
+		}
+	}
+	return nil, errors.New("no matching slot found")
+}
+
+// h might be nil (default header), otherwise its mode must be ModeSlots.
+// don't forget to annihilate the passwords!
+func EncryptForRecipients(h *Header, recipients []Recipient, data []byte) ([]byte, error) {
+	if h == nil {
+		h = NewHeader(ModeSlots)
+	}
+	if h.Mode != ModeSlots {
+		return nil, fmt.Errorf("wrong mode %s", ModeName(h.Mode))
+	}
+	if err := h.validate(); err != nil {
+		return nil, err
+	}
+	if len(recipients) == 0 || len(recipients) > MaxSlots {
+		return nil, fmt.Errorf("wrong number of recipients %d", len(recipients))
+	}
+
+	fileKey := make([]byte, FileKeySize)
+	defer AnnihilateData(fileKey)
+	if err := StochasticRand(fileKey); err != nil {
+		return nil, err
+	}
+
+	c := &slotsContainer{header: h.Bytes()}
+	for _, r := range recipients {
+		slot, err := wrapFileKey(fileKey, r, c.header)
+		if err != nil {
+			return nil, err
+		}
+		c.slots = append(c.slots, slot)
+	}
+	c.mac = c.computeMac(fileKey)
+
+	data, _ = addPaddingAndSpacing(data, nil)
+	dataKey := getDataKey(fileKey, c.header)
+	defer AnnihilateData(dataKey)
+	body, err := encrypt(dataKey, data, nil)
+	if err != nil {
+		return nil, err
+	}
+	c.body = body
+	res := c.serialize()
+	AnnihilateData(body)
+	return res, nil
+}
+
+func decryptWithSlots(key []byte, private bool, data []byte) (res []byte, spacing []byte, err error) {
+	c, err := parseSlots(data)
+	if err != nil {
+		return nil, nil, err
+	}
+	fileKey, err := c.findFileKey(key, private)
+	if err != nil {
+		return nil, nil, err
+	}
+	dataKey := getDataKey(fileKey, c.header)
+	AnnihilateData(fileKey)
+	defer AnnihilateData(dataKey)
+	return decrypt(dataKey, c.body, nil)
+}
+
+// the key (password or private key) must open one of the existing slots
+func AddRecipient(key []byte, private bool, data []byte, r Recipient) ([]byte, error) {
+	c, err := parseSlots(data)
+	if err != nil {
+		return nil, err
+	}
+	if len(c.slots) >= MaxSlots {
+		return nil, errors.New("too many slots")
+	}
+	fileKey, err := c.findFileKey(key, private)
+	if err != nil {
+		return nil, err
+	}
+	defer AnnihilateData(fileKey)
+
+	slot, err := wrapFileKey(fileKey, r, c.header)
+	if err != nil {
+		return nil, err
+	}
+	c.slots = append(c.slots, slot)
+	c.mac = c.computeMac(fileKey)
+	return c.serialize(), nil
+}
+
+// the key (password or private key) must open one of the existing slots, since the slot table is authenticated
+func RemoveRecipient(key []byte, private bool, data []byte, index int) ([]byte, error) {
+	c, err := parseSlots(data)
+	if err != nil {
+		return nil, err
+	}
+	if index < 0 || index >= len(c.slots) {
+		return nil, fmt.Errorf("wrong slot index %d", index)
+	}
+	if len(c.slots) == 1 {
+		return nil, errors.New("the last slot can not be removed")
+	}
+	fileKey, err := c.findFileKey(key, private)
+	if err != nil {
+		return nil, err
+	}
+	defer AnnihilateData(fileKey)
+
+	c.slots = append(c.slots[:index], c.slots[index+1:]...)
+	c.mac = c.computeMac(fileKey)
+	return c.serialize(), nil
+}
+
+// returns the type of each slot
+func GetSlots(data []byte) ([]byte, error) {
+	c, err := parseSlots(data)
+	if err != nil {
+		return nil, err
+	}
+	res := make([]byte, len(c.slots))
+	for i, s := range c.slots {
+		res[i] = s[0]
+	}
+	return res, nil
+}
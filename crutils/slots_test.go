@@ -0,0 +1,203 @@
+package crutils
+
+import (
+	"bytes"
+	mrand "math/rand"
+	"testing"
+	"time"
+)
+
+func TestMultiRecipientEncryption(t *testing.T) {
+	seed := time.Now().Unix()
+	mrand.Seed(seed)
+
+	pub, priv, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, priv2, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	password := generateRandomBytes(t, false)
+	password2 := generateRandomBytes(t, false)
+	data := generateRandomBytes(t, true)
+	orig := make([]byte, len(data))
+	copy(orig, data)
+
+	h := NewHeader(ModeSlots)
+	h.SetKdf(KdfArgon2id)
+	h.KdfParams = testKdfParams[KdfArgon2id]
+	recipients := []Recipient{{Password: password}, {PublicKey: pub}}
+	encrypted, err := EncryptForRecipients(h, recipients, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !IsMultiRecipient(encrypted) {
+		t.Fatal("wrong header")
+	}
+	slots, err := GetSlots(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(slots, []byte{SlotPassword, SlotPublicKey}) {
+		t.Fatalf("wrong slots %v", slots)
+	}
+
+	dup := make([]byte, len(encrypted))
+	copy(dup, encrypted)
+	if _, _, err = Decrypt(password2, dup); err == nil {
+		t.Fatal("decrypted with wrong password")
+	}
+	copy(dup, encrypted)
+	if _, _, err = DecryptWithPrivateKey(priv2, dup); err == nil {
+		t.Fatal("decrypted with wrong private key")
+	}
+	copy(dup, encrypted)
+	dup[8]++ // kdf params
+	if _, _, err = Decrypt(password, dup); err == nil {
+		t.Fatal("modified header not detected")
+	}
+
+	copy(dup, encrypted)
+	decrypted, _, err := Decrypt(password, dup)
+	if err != nil {
+		t.Fatalf("seed %d: %s", seed, err)
+	}
+	if !bytes.Equal(decrypted, orig) {
+		t.Fatalf("decrypted with password != expected, seed %d", seed)
+	}
+	copy(dup, encrypted)
+	decrypted, _, err = DecryptWithPrivateKey(priv, dup)
+	if err != nil {
+		t.Fatalf("seed %d: %s", seed, err)
+	}
+	if !bytes.Equal(decrypted, orig) {
+		t.Fatalf("decrypted with private key != expected, seed %d", seed)
+	}
+}
+
+func TestAddRemoveRecipient(t *testing.T) {
+	seed := time.Now().Unix()
+	mrand.Seed(seed)
+
+	pub, priv, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	password := generateRandomBytes(t, false)
+	password2 := generateRandomBytes(t, false)
+	data := generateRandomBytes(t, false)
+	orig := make([]byte, len(data))
+	copy(orig, data)
+
+	encrypted, err := EncryptForRecipients(nil, []Recipient{{Password: password}}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	body := encrypted[HeaderSize+1+SlotSize+slotsMacSize:]
+
+	if _, err = AddRecipient(password2, false, encrypted, Recipient{PublicKey: pub}); err == nil {
+		t.Fatal("recipient added with wrong password")
+	}
+	encrypted, err = AddRecipient(password, false, encrypted, Recipient{PublicKey: pub})
+	if err != nil {
+		t.Fatal(err)
+	}
+	encrypted, err = AddRecipient(priv, true, encrypted, Recipient{Password: password2})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(encrypted[HeaderSize+1+3*SlotSize+slotsMacSize:], body) {
+		t.Fatal("encrypted data was modified")
+	}
+
+	if _, err = RemoveRecipient(password2[1:], false, encrypted, 0); err == nil {
+		t.Fatal("recipient removed with wrong password")
+	}
+	encrypted, err = RemoveRecipient(password2, false, encrypted, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	slots, err := GetSlots(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(slots, []byte{SlotPublicKey, SlotPassword}) {
+		t.Fatalf("wrong slots %v", slots)
+	}
+
+	dup := make([]byte, len(encrypted))
+	copy(dup, encrypted)
+	if _, _, err = Decrypt(password, dup); err == nil {
+		t.Fatal("decrypted with removed password")
+	}
+	copy(dup, encrypted)
+	decrypted, _, err := Decrypt(password2, dup)
+	if err != nil {
+		t.Fatalf("seed %d: %s", seed, err)
+	}
+	if !bytes.Equal(decrypted, orig) {
+		t.Fatalf("decrypted != expected, seed %d", seed)
+	}
+
+	if encrypted, err = RemoveRecipient(priv, true, encrypted, 1); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = RemoveRecipient(priv, true, encrypted, 0); err == nil {
+		t.Fatal("the last slot removed")
+	}
+	if _, err = RemoveRecipient(priv, true, encrypted, 1); err == nil {
+		t.Fatal("wrong index accepted")
+	}
+}
+
+func TestSlotsTampering(t *testing.T) {
+	pub, priv, err := GenerateKeyPair()
+	if err != nil {
+		t.Fatal(err)
+	}
+	password := generateRandomBytes(t, false)
+	data := generateRandomBytes(t, false)
+	encrypted, err := EncryptForRecipients(nil, []Recipient{{Password: password}, {PublicKey: pub}}, data)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c, err := parseSlots(encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the slot is removed without the key
+	removed := &slotsContainer{header: c.header, slots: c.slots[1:], mac: c.mac, body: c.body}
+	if _, _, err = DecryptWithPrivateKey(priv, removed.serialize()); err == nil {
+		t.Fatal("removed slot is not detected")
+	}
+
+	// the slots are reordered
+	reordered := &slotsContainer{header: c.header, slots: [][]byte{c.slots[1], c.slots[0]}, mac: c.mac, body: c.body}
+	if _, _, err = Decrypt(password, reordered.serialize()); err == nil {
+		t.Fatal("reordered slots are not detected")
+	}
+
+	// the header is changed, and the mac is recomputed (e.g. by one of the recipients):
+	// the public key slot does not depend on the header, but the data does
+	fileKey, err := c.findFileKey(password, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h := NewHeader(ModeSlots)
+	h.SetKdf(KdfScrypt)
+	h.KdfParams = testKdfParams[KdfScrypt]
+	modified := &slotsContainer{header: h.Bytes(), slots: c.slots[1:], body: c.body}
+	modified.mac = modified.computeMac(fileKey)
+	if _, _, err = DecryptWithPrivateKey(priv, modified.serialize()); err == nil {
+		t.Fatal("modified header is not detected")
+	}
+
+	dup := make([]byte, len(encrypted))
+	copy(dup, encrypted)
+	if _, _, err = DecryptWithPrivateKey(priv, dup); err != nil {
+		t.Fatal(err)
+	}
+}
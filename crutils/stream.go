@@ -0,0 +1,274 @@
+package crutils
+
+import (
+	"bufio"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/gluk256/crypto/algo/keccak"
+	"github.com/gluk256/crypto/algo/primitives"
+	"github.com/gluk256/crypto/algo/rcx"
+)
+
+// The stream format allows to process the data of arbitrary size without loading it into memory.
+// The data is split into chunks, each chunk is encrypted with the same layers as in the main
+// encryption function (Keccak, RCX, AES-GCM, Keccak), but with its own subkeys.
+// Every chunk is authenticated separately, the last chunk is explicitly marked as final,
+// so that truncation and reordering of the chunks is detected.
+// Unlike Encrypt(), the stream format does not use padding and spacing (the size is not hidden).
+//
+// stream layout: [header][salt][chunk_0]...[chunk_N], where the last chunk might be shorter (or even empty).
+
+const (
+	StreamChunkSize       = 1024 * 1024
+	StreamEncryptedChunk  = StreamChunkSize + AesEncryptedSizeDiff
+	streamRcxIterations   = 4 // streams are supposed to be big, as the biggest files in Encrypt()
+	streamChunkIndexBytes = 8
+	chunkKeyCustomization = "xcry chunk key"
+)
+
+type streamWriter struct {
+	w         io.Writer
+	keyholder *SecureBuffer
+	buf       []byte // plain text of the current chunk
+	chunkSize int
+	index     uint64
+	closed    bool
+	err       error
+}
+
+type streamReader struct {
+	r         *bufio.Reader
+	keyholder *SecureBuffer
+	buf       []byte // encrypted chunk, decrypted in place
+	plain     []byte // decrypted data which is not yet read, points into buf
+	index     uint64
+	done      bool
+	err       error
+}
+
+// don't forget to annihilate the key!
+func NewEncryptingWriter(key []byte, w io.Writer) (io.WriteCloser, error) {
+	h := NewHeader(ModeStream)
+	if _, err := w.Write(h.Bytes()); err != nil {
+		return nil, err
+	}
+	return newStreamWriter(h, key, w)
+}
+
+// don't forget to annihilate the key!
+func NewDecryptingReader(key []byte, r io.Reader) (io.ReadCloser, error) {
+	hdr := make([]byte, HeaderSize)
+	if _, err := io.ReadFull(r, hdr); err != nil {
+		return nil, fmt.Errorf("failed to read header: %s", err.Error())
+	}
+	h, err := ParseHeader(hdr)
+	if err != nil {
+		return nil, err
+	}
+	if h.Mode != ModeStream {
+		return nil, fmt.Errorf("wrong mode: expected %s, got %s", ModeName(ModeStream), ModeName(h.Mode))
+	}
+	return newStreamReader(h, hdr, key, r)
+}
+
+// the header must be already written
+func newStreamWriter(h *Header, key []byte, w io.Writer) (*streamWriter, error) {
+	salt, err := GenerateSalt()
+	if err != nil {
+		return nil, err
+	}
+	if _, err = w.Write(salt); err != nil {
+		return nil, err
+	}
+
+	keyholder, err := generateKeysWithHeader(key, salt, h.Bytes())
+	if err != nil {
+		return nil, err
+	}
+	s := &streamWriter{w: w, keyholder: keyholder, chunkSize: int(h.ChunkSize)}
+	s.buf = make([]byte, 0, s.chunkSize+AesEncryptedSizeDiff)
+	return s, nil
+}
+
+// the header must be already read, and the reader must point to the salt
+func newStreamReader(h *Header, hdr []byte, key []byte, r io.Reader) (*streamReader, error) {
+	salt := make([]byte, SaltSize)
+	if _, err := io.ReadFull(r, salt); err != nil {
+		return nil, fmt.Errorf("failed to read salt: %s", err.Error())
+	}
+
+	keyholder, err := generateKeysWithHeader(key, salt, hdr)
+	if err != nil {
+		return nil, err
+	}
+	s := &streamReader{r: bufio.NewReader(r), keyholder: keyholder}
+	s.buf = make([]byte, int(h.ChunkSize)+AesEncryptedSizeDiff)
+	return s, nil
+}
+
+func (s *streamWriter) Write(p []byte) (n int, err error) {
+	if s.closed {
+		return 0, errors.New("write to closed stream")
+	}
+	for len(p) > 0 && s.err == nil {
+		if len(s.buf) == s.chunkSize {
+			// the chunk is full, and there is more data: so it is definitely not the last one
+			s.flush(false)
+			continue
+		}
+		sz := primitives.Min(s.chunkSize-len(s.buf), len(p))
+		s.buf = append(s.buf, p[:sz]...)
+		p = p[sz:]
+		n += sz
+	}
+	return n, s.err
+}
+
+func (s *streamWriter) flush(final bool) {
+	if s.err != nil {
+		return
+	}
+	encrypted, err := encryptChunk(s.keyholder.Bytes(), s.index, final, s.buf)
+	if err == nil {
+		_, err = s.w.Write(encrypted)
+	}
+	AnnihilateData(encrypted)
+	s.buf = s.buf[:0]
+	s.index++
+	s.err = err
+}
+
+// writes the final chunk, and destroys the keys. the underlying writer is not closed.
+func (s *streamWriter) Close() error {
+	if s.closed {
+		return s.err
+	}
+	s.flush(true)
+	s.closed = true
+	s.keyholder.Destroy()
+	AnnihilateData(s.buf[:cap(s.buf)])
+	return s.err
+}
+
+func (s *streamReader) Read(p []byte) (int, error) {
+	for len(s.plain) == 0 {
+		if s.err != nil {
+			return 0, s.err
+		}
+		if s.done {
+			return 0, io.EOF
+		}
+		s.next()
+	}
+	n := copy(p, s.plain)
+	s.plain = s.plain[n:]
+	return n, nil
+}
+
+func (s *streamReader) next() {
+	n, err := io.ReadFull(s.r, s.buf)
+	final := false
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		final = true
+	} else if err != nil {
+		s.fail(err)
+		return
+	} else if _, err = s.r.Peek(1); err == io.EOF {
+		final = true
+	}
+
+	if n < AesEncryptedSizeDiff {
+		s.fail(errors.New("stream is truncated"))
+		return
+	}
+
+	s.plain, err = decryptChunk(s.keyholder.Bytes(), s.index, final, s.buf[:n])
+	if err != nil {
+		s.fail(fmt.Errorf("failed to decrypt chunk %d: %s", s.index, err.Error()))
+		return
+	}
+	s.index++
+	if final {
+		s.done = true
+		s.keyholder.Destroy()
+	}
+}
+
+func (s *streamReader) fail(err error) {
+	s.err = err
+	s.plain = nil
+	s.keyholder.Destroy()
+	AnnihilateData(s.buf)
+}
+
+// destroys the keys and the decrypted data, which is not yet read
+func (s *streamReader) Close() error {
+	if s.err == nil {
+		s.fail(errors.New("read from closed stream"))
+	}
+	return nil
+}
+
+// each chunk gets its own subkey of the same size, derived from the key and the chunk index.
+// simple concatenation is not enough, since RC4 key schedule only uses the first 256 bytes of the key.
+func getChunkKey(key []byte, index uint64) []byte {
+	idx := make([]byte, streamChunkIndexBytes)
+	binary.LittleEndian.PutUint64(idx, index)
+	return keccak.Kmac256(key, idx, []byte(chunkKeyCustomization), len(key))
+}
+
+func getChunkAesSalt(salt []byte, index uint64) []byte {
+	res := make([]byte, AesSaltSize)
+	copy(res, salt)
+	x := binary.LittleEndian.Uint64(res[AesSaltSize-streamChunkIndexBytes:])
+	binary.LittleEndian.PutUint64(res[AesSaltSize-streamChunkIndexBytes:], x^index)
+	return res
+}
+
+func getChunkAdditionalData(final bool) []byte {
+	if final {
+		return []byte{1}
+	}
+	return []byte{0}
+}
+
+func encryptChunk(keyholder []byte, index uint64, final bool, data []byte) ([]byte, error) {
+	k1 := getChunkKey(getKey1(keyholder), index)
+	k2 := getChunkKey(getKey2(keyholder), index)
+	kr := getChunkKey(getRcxKey(keyholder), index)
+	defer AnnihilateData(k1)
+	defer AnnihilateData(k2)
+	defer AnnihilateData(kr)
+
+	EncryptInplaceKeccak(k1, data)
+	AnnihilateData(rcx.EncryptInplaceRcx(kr, data, streamRcxIterations))
+	salt := getChunkAesSalt(getAesSalt(keyholder), index)
+	res, err := encryptAES(getAesKey(keyholder), salt, data, getChunkAdditionalData(final))
+	if err != nil {
+		return nil, err
+	}
+	EncryptInplaceKeccak(k2, res)
+	return res, nil
+}
+
+func decryptChunk(keyholder []byte, index uint64, final bool, data []byte) ([]byte, error) {
+	k1 := getChunkKey(getKey1(keyholder), index)
+	k2 := getChunkKey(getKey2(keyholder), index)
+	kr := getChunkKey(getRcxKey(keyholder), index)
+	defer AnnihilateData(k1)
+	defer AnnihilateData(k2)
+	defer AnnihilateData(kr)
+
+	EncryptInplaceKeccak(k2, data)
+	salt := getChunkAesSalt(getAesSalt(keyholder), index)
+	res, err := decryptAES(getAesKey(keyholder), salt, data, getChunkAdditionalData(final))
+	if err != nil {
+		return nil, err
+	}
+	AnnihilateData(rcx.DecryptInplaceRcx(kr, res, streamRcxIterations))
+	EncryptInplaceKeccak(k1, res)
+	return res, nil
+}
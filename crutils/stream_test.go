@@ -0,0 +1,143 @@
+package crutils
+
+import (
+	"bytes"
+	"io"
+	"io/ioutil"
+	mrand "math/rand"
+	"testing"
+	"time"
+
+	"github.com/gluk256/crypto/algo/primitives"
+)
+
+const streamPrefix = HeaderSize + SaltSize
+
+func encryptStream(t *testing.T, key []byte, data []byte) []byte {
+	var buf bytes.Buffer
+	w, err := NewEncryptingWriter(key, &buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// write in pieces of random size
+	for p := data; len(p) > 0; {
+		sz := primitives.Min(mrand.Intn(StreamChunkSize/3)+1, len(p))
+		if _, err = w.Write(p[:sz]); err != nil {
+			t.Fatal(err)
+		}
+		p = p[sz:]
+	}
+	if err = w.Close(); err != nil {
+		t.Fatal(err)
+	}
+	return buf.Bytes()
+}
+
+func decryptStream(key []byte, data []byte) ([]byte, error) {
+	r, err := NewDecryptingReader(key, bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	defer r.Close()
+	return ioutil.ReadAll(r)
+}
+
+func TestStream(t *testing.T) {
+	seed := time.Now().Unix()
+	mrand.Seed(seed)
+
+	key := generateRandomBytes(t, false)
+	sizes := []int{0, 1, 77, StreamChunkSize - 1, StreamChunkSize, StreamChunkSize + 1, StreamChunkSize*2 + 12345}
+	for _, sz := range sizes {
+		data := make([]byte, sz)
+		mrand.Read(data)
+		encrypted := encryptStream(t, key, data)
+		chunks := sz / StreamChunkSize
+		if sz%StreamChunkSize != 0 || sz == 0 {
+			chunks++
+		}
+		expected := streamPrefix + sz + chunks*AesEncryptedSizeDiff
+		if len(encrypted) != expected {
+			t.Fatalf("wrong size [%d vs. %d], data size %d", len(encrypted), expected, sz)
+		}
+		if sz > 0 && !primitives.IsDeepNotEqual(data, encrypted[streamPrefix:], sz) {
+			t.Fatalf("deep non-equal test failed, size %d, seed %d", sz, seed)
+		}
+
+		decrypted, err := decryptStream(key, encrypted)
+		if err != nil {
+			t.Fatalf("decryption failed, size %d, seed %d: %s", sz, seed, err)
+		}
+		if !bytes.Equal(decrypted, data) {
+			t.Fatalf("decrypted != expected, size %d, seed %d", sz, seed)
+		}
+	}
+}
+
+func TestStreamTampering(t *testing.T) {
+	seed := time.Now().Unix()
+	mrand.Seed(seed)
+
+	key := generateRandomBytes(t, false)
+	data := make([]byte, StreamChunkSize*3+100)
+	mrand.Read(data)
+	encrypted := encryptStream(t, key, data)
+
+	wrongKey := make([]byte, len(key))
+	copy(wrongKey, key)
+	wrongKey[0]++
+	if _, err := decryptStream(wrongKey, encrypted); err == nil {
+		t.Fatal("decrypted with wrong key")
+	}
+
+	modified := make([]byte, len(encrypted))
+	copy(modified, encrypted)
+	modified[streamPrefix+StreamEncryptedChunk+mrand.Intn(StreamEncryptedChunk)]++
+	if _, err := decryptStream(key, modified); err == nil {
+		t.Fatalf("decrypted modified data, seed %d", seed)
+	}
+
+	// truncated at the chunk boundary
+	truncated := encrypted[:streamPrefix+StreamEncryptedChunk*2]
+	if _, err := decryptStream(key, truncated); err == nil {
+		t.Fatal("truncation not detected")
+	}
+
+	// the first two chunks swapped
+	swapped := make([]byte, 0, len(encrypted))
+	swapped = append(swapped, encrypted[:streamPrefix]...)
+	swapped = append(swapped, encrypted[streamPrefix+StreamEncryptedChunk:streamPrefix+StreamEncryptedChunk*2]...)
+	swapped = append(swapped, encrypted[streamPrefix:streamPrefix+StreamEncryptedChunk]...)
+	swapped = append(swapped, encrypted[streamPrefix+StreamEncryptedChunk*2:]...)
+	if _, err := decryptStream(key, swapped); err == nil {
+		t.Fatal("reordering not detected")
+	}
+
+	// make sure the original is still intact
+	decrypted, err := decryptStream(key, encrypted)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Equal(decrypted, data) {
+		t.Fatalf("decrypted != expected, seed %d", seed)
+	}
+}
+
+func TestStreamEmpty(t *testing.T) {
+	key := []byte("7eab42de4c3ceb9235fc91acffe746b29c29a8c366b7c60e4e67c466f36a4304")
+	if _, err := NewDecryptingReader(key, bytes.NewReader(nil)); err == nil {
+		t.Fatal("created reader without header")
+	}
+	h := NewHeader(ModeStream)
+	if _, err := NewDecryptingReader(key, bytes.NewReader(h.Bytes())); err == nil {
+		t.Fatal("created reader without salt")
+	}
+	empty := append(h.Bytes(), make([]byte, SaltSize)...)
+	r, err := NewDecryptingReader(key, bytes.NewReader(empty))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, err = r.Read(make([]byte, 16)); err == nil || err == io.EOF {
+		t.Fatal("missing final chunk not detected")
+	}
+}
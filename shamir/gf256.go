@@ -0,0 +1,80 @@
+package shamir
+
+// arithmetic in GF(2^8) with the polynomial x^8 + x^4 + x^3 + x + 1 (same as AES)
+
+var (
+	expTable [512]byte
+	logTable [256]byte
+)
+
+func init() {
+	x := byte(1)
+	for i := 0; i < 255; i++ {
+		expTable[i] = x
+		logTable[x] = byte(i)
+		x = mulSlow(x, 3) // 3 is the generator
+	}
+	for i := 255; i < len(expTable); i++ {
+		expTable[i] = expTable[i-255]
+	}
+}
+
+// used only for table generation
+func mulSlow(a byte, b byte) (res byte) {
+	for b != 0 {
+		if b&1 != 0 {
+			res ^= a
+		}
+		hi := a & 0x80
+		a <<= 1
+		if hi != 0 {
+			a ^= 0x1b
+		}
+		b >>= 1
+	}
+	return res
+}
+
+func add(a byte, b byte) byte {
+	return a ^ b
+}
+
+func mul(a byte, b byte) byte {
+	if a == 0 || b == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+int(logTable[b])]
+}
+
+// b must not be zero
+func div(a byte, b byte) byte {
+	if a == 0 {
+		return 0
+	}
+	return expTable[int(logTable[a])+255-int(logTable[b])]
+}
+
+// evaluates the polynomial with given coefficients at point x (Horner's method)
+func evaluate(coefficients []byte, x byte) byte {
+	var res byte
+	for i := len(coefficients) - 1; i >= 0; i-- {
+		res = add(mul(res, x), coefficients[i])
+	}
+	return res
+}
+
+// Lagrange interpolation at point zero
+func interpolate(xs []byte, ys []byte) byte {
+	var res byte
+	for i := range xs {
+		num, den := byte(1), byte(1)
+		for j := range xs {
+			if i != j {
+				num = mul(num, xs[j])
+				den = mul(den, add(xs[i], xs[j]))
+			}
+		}
+		res = add(res, mul(ys[i], div(num, den)))
+	}
+	return res
+}
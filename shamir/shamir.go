@@ -0,0 +1,126 @@
+package shamir
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+
+	"github.com/gluk256/crypto/algo/keccak"
+	"github.com/gluk256/crypto/crutils"
+)
+
+// Shamir secret sharing over GF(256): the secret is split into N shares, any K of them
+// are enough to recover the secret, while K-1 shares reveal nothing about it.
+// Each byte of the secret is the free coefficient of its own random polynomial of degree K-1.
+//
+// The secret is extended with checksum before splitting, so that the wrong combination of shares is detected.
+// Each share also has its own checksum, so that the corrupted share is detected before combining.
+//
+// share layout: [x][threshold][payload][checksum]
+
+const (
+	MaxShares     = 255
+	ChecksumSize  = 4
+	ShareSizeDiff = 2 + ChecksumSize*2 // the share is bigger than the secret
+)
+
+func getChecksum(data []byte) []byte {
+	return keccak.Digest(data, ChecksumSize)
+}
+
+// don't forget to annihilate the secret!
+func Split(secret []byte, n int, k int) ([][]byte, error) {
+	if len(secret) == 0 {
+		return nil, errors.New("empty secret")
+	}
+	if k < 2 || k > n || n > MaxShares {
+		return nil, fmt.Errorf("wrong params: %d of %d", k, n)
+	}
+
+	full := make([]byte, 0, len(secret)+ChecksumSize)
+	full = append(full, secret...)
+	full = append(full, getChecksum(secret)...)
+	defer crutils.AnnihilateData(full)
+
+	shares := make([][]byte, n)
+	for i := range shares {
+		shares[i] = make([]byte, 2, len(full)+2+ChecksumSize)
+		shares[i][0] = byte(i + 1)
+		shares[i][1] = byte(k)
+	}
+
+	coefficients := make([]byte, k)
+	defer crutils.AnnihilateData(coefficients)
+	for _, b := range full {
+		if err := crutils.StochasticRand(coefficients[1:]); err != nil {
+			return nil, err
+		}
+		coefficients[0] = b
+		for i := range shares {
+			shares[i] = append(shares[i], evaluate(coefficients, shares[i][0]))
+		}
+	}
+
+	for i := range shares {
+		shares[i] = append(shares[i], getChecksum(shares[i])...)
+	}
+	return shares, nil
+}
+
+func VerifyShare(share []byte) error {
+	if len(share) <= ShareSizeDiff {
+		return fmt.Errorf("share is too small [%d bytes]", len(share))
+	}
+	data := share[:len(share)-ChecksumSize]
+	if !bytes.Equal(getChecksum(data), share[len(data):]) {
+		return errors.New("share checksum mismatch")
+	}
+	if share[0] == 0 || share[1] < 2 {
+		return errors.New("wrong share params")
+	}
+	return nil
+}
+
+// don't forget to annihilate the result!
+func Combine(shares [][]byte) ([]byte, error) {
+	if len(shares) == 0 {
+		return nil, errors.New("no shares")
+	}
+	sz := len(shares[0])
+	k := int(shares[0][1])
+	xs := make([]byte, 0, len(shares))
+	for i, s := range shares {
+		if err := VerifyShare(s); err != nil {
+			return nil, fmt.Errorf("share %d: %s", i, err.Error())
+		}
+		if len(s) != sz || int(s[1]) != k {
+			return nil, fmt.Errorf("share %d does not belong to the same secret", i)
+		}
+		if bytes.IndexByte(xs, s[0]) >= 0 {
+			return nil, fmt.Errorf("share %d is duplicated", i)
+		}
+		xs = append(xs, s[0])
+	}
+	if len(shares) < k {
+		return nil, fmt.Errorf("not enough shares: %d of %d", len(shares), k)
+	}
+
+	// exactly k shares are used
+	xs = xs[:k]
+	ys := make([]byte, k)
+	full := make([]byte, sz-2-ChecksumSize)
+	for j := range full {
+		for i := 0; i < k; i++ {
+			ys[i] = shares[i][j+2]
+		}
+		full[j] = interpolate(xs, ys)
+	}
+	crutils.AnnihilateData(ys)
+
+	secret := full[:len(full)-ChecksumSize]
+	if !bytes.Equal(getChecksum(secret), full[len(secret):]) {
+		crutils.AnnihilateData(full)
+		return nil, errors.New("secret checksum mismatch")
+	}
+	return secret, nil
+}
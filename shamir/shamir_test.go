@@ -0,0 +1,104 @@
+package shamir
+
+import (
+	"bytes"
+	mrand "math/rand"
+	"testing"
+	"time"
+)
+
+func TestField(t *testing.T) {
+	for a := 1; a < 256; a++ {
+		for b := 1; b < 256; b++ {
+			p := mul(byte(a), byte(b))
+			if p != mulSlow(byte(a), byte(b)) {
+				t.Fatalf("mul failed: %d * %d", a, b)
+			}
+			if div(p, byte(b)) != byte(a) {
+				t.Fatalf("div failed: %d / %d", p, b)
+			}
+		}
+	}
+	if mul(0x57, 0x83) != 0xc1 { // FIPS 197, section 4.2
+		t.Fatal("wrong polynomial")
+	}
+}
+
+func TestSplitCombine(t *testing.T) {
+	seed := time.Now().Unix()
+	mrand.Seed(seed)
+
+	for i := 0; i < 32; i++ {
+		n := mrand.Intn(10) + 2
+		k := mrand.Intn(n-1) + 2
+		secret := make([]byte, mrand.Intn(100)+1)
+		mrand.Read(secret)
+
+		shares, err := Split(secret, n, k)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(shares) != n || len(shares[0]) != len(secret)+ShareSizeDiff {
+			t.Fatalf("wrong shares: %d, %d", len(shares), len(shares[0]))
+		}
+
+		// any k shares in any order
+		mrand.Shuffle(n, func(a, b int) { shares[a], shares[b] = shares[b], shares[a] })
+		res, err := Combine(shares[:k])
+		if err != nil {
+			t.Fatalf("%d of %d, seed %d: %s", k, n, seed, err)
+		}
+		if !bytes.Equal(res, secret) {
+			t.Fatalf("wrong secret, %d of %d, seed %d", k, n, seed)
+		}
+		res, err = Combine(shares)
+		if err != nil || !bytes.Equal(res, secret) {
+			t.Fatalf("all shares failed, %d of %d, seed %d", k, n, seed)
+		}
+
+		if _, err = Combine(shares[:k-1]); err == nil {
+			t.Fatalf("combined with insufficient shares, %d of %d", k, n)
+		}
+	}
+}
+
+func TestShareIntegrity(t *testing.T) {
+	secret := []byte("the master password")
+	shares, err := Split(secret, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other, err := Split(secret, 5, 3)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Equal(shares[0], other[0]) {
+		t.Fatal("coefficients are not random")
+	}
+
+	corrupted := make([]byte, len(shares[1]))
+	copy(corrupted, shares[1])
+	corrupted[5]++
+	if VerifyShare(corrupted) == nil {
+		t.Fatal("corrupted share not detected")
+	}
+	if _, err = Combine([][]byte{shares[0], corrupted, shares[2]}); err == nil {
+		t.Fatal("combined with corrupted share")
+	}
+	if _, err = Combine([][]byte{shares[0], shares[0], shares[2]}); err == nil {
+		t.Fatal("combined with duplicated share")
+	}
+	if _, err = Combine([][]byte{shares[0], other[1], shares[2]}); err == nil {
+		t.Fatal("combined shares of different splits")
+	}
+
+	if _, err = Split(secret, 5, 1); err == nil {
+		t.Fatal("threshold 1 accepted")
+	}
+	if _, err = Split(secret, 3, 4); err == nil {
+		t.Fatal("threshold bigger than number of shares accepted")
+	}
+	if _, err = Split(nil, 3, 2); err == nil {
+		t.Fatal("empty secret accepted")
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/gluk256/crypto/algo/primitives"
+	"github.com/gluk256/crypto/crutils"
 )
 
 func TestShuffle(t *testing.T) {
@@ -25,3 +26,20 @@ func singleShuffleTest(t *testing.T, ext bool) {
 		t.Fatalf("shuffle test failed with seed %d", seed)
 	}
 }
+
+func TestGrowBuffer(t *testing.T) {
+	buf := crutils.NewSecureBuffer(inputBufferSize)
+	for i := range buf.Bytes() {
+		buf.Bytes()[i] = byte(i)
+	}
+	buf = growBuffer(buf)
+	defer buf.Destroy()
+	if buf.Len() != inputBufferSize*2 {
+		t.Fatalf("wrong size %d", buf.Len())
+	}
+	for i := 0; i < inputBufferSize; i++ {
+		if buf.Bytes()[i] != byte(i) {
+			t.Fatalf("data is not copied, index %d", i)
+		}
+	}
+}
@@ -2,13 +2,14 @@ package terminal
 
 import (
 	"bufio"
+	"errors"
 	"fmt"
+	"io"
 	"math/rand"
 	"os"
 	"os/exec"
 	"runtime"
 	"strings"
-	"syscall"
 
 	shell "golang.org/x/crypto/ssh/terminal"
 
@@ -21,8 +22,23 @@ var AlphabetExt = []byte("abcdefghijklmnopqrstuvwxyz 0123456789!@#$%^&*()_+-=[];
 var alphabet []byte
 var scrambledAlphabet []byte
 var sz = 0
+
+const inputBufferSize = 1024 // initial size, the buffer grows as needed (the input is not limited)
+
+var input = os.Stdin
 var inputReader = bufio.NewReader(os.Stdin)
 
+// redirects all the terminal input to /dev/tty, so that stdin can be used for data
+func UseTty() error {
+	f, err := os.OpenFile("/dev/tty", os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	input = f
+	inputReader = bufio.NewReader(f)
+	return nil
+}
+
 func printSpaced(s []byte) {
 	var x string
 	delim := string("│")
@@ -77,13 +93,24 @@ func resetParams() {
 	scrambledAlphabet = nil
 }
 
-func secureRead(ext bool) []byte {
+// doubles the size of the buffer, the old one is destroyed
+func growBuffer(buf *crutils.SecureBuffer) *crutils.SecureBuffer {
+	res := crutils.NewSecureBuffer(buf.Len() * 2)
+	copy(res.Bytes(), buf.Bytes())
+	buf.Destroy()
+	return res
+}
+
+// don't forget to destroy the result!
+func secureRead(ext bool) *crutils.SecureBuffer {
 	initParams(ext)
 	defer resetParams() // explicitly allow garbage collection
 	printSpaced(alphabet)
 	fmt.Println()
 	b := make([]byte, 1)
-	s := make([]byte, 0, 256)
+	buf := crutils.NewSecureBuffer(inputBufferSize)
+	defer func() { buf.Destroy() }()
+	n := 0
 	var next byte
 	done := false
 
@@ -91,7 +118,7 @@ func secureRead(ext bool) []byte {
 		randomizeAlphabet()
 		fmt.Print("\r")
 		printSpaced(scrambledAlphabet)
-		_, err := os.Stdin.Read(b)
+		_, err := input.Read(b)
 		if err != nil {
 			fmt.Printf(">>>>>> Input Error: %s \n", err)
 			return nil
@@ -100,13 +127,18 @@ func secureRead(ext bool) []byte {
 		switch b[0] {
 		case 27: // escape: only reshuffle, do nothing
 		case 127: // backspace
-			if i := len(s); i > 0 {
-				s = s[:i-1]
+			if n > 0 {
+				n--
+				buf.Bytes()[n] = 0
 			}
 		default:
 			next, done = decryptByte(b[0])
 			if !done && next != '`' {
-				s = append(s, next)
+				if n == buf.Len() {
+					buf = growBuffer(buf)
+				}
+				buf.Bytes()[n] = next
+				n++
 			}
 		}
 		crutils.CollectEntropy()
@@ -115,7 +147,7 @@ func secureRead(ext bool) []byte {
 	fmt.Print("\r")
 	printSpaced(alphabet)
 	fmt.Println()
-	return s
+	return crutils.NewSecureBufferFrom(buf.Bytes()[:n])
 }
 
 func decryptByte(c byte) (byte, bool) {
@@ -128,7 +160,22 @@ func decryptByte(c byte) (byte, bool) {
 	return byte(0), true
 }
 
+// copies the locked input into the ordinary memory (only for the data which is not a key)
+func unlock(b *crutils.SecureBuffer) []byte {
+	if b == nil {
+		return nil
+	}
+	res := make([]byte, b.Len())
+	copy(res, b.Bytes())
+	b.Destroy()
+	return res
+}
+
 func SecureInputLinux(ext bool) []byte {
+	return unlock(secureInputLinux(ext))
+}
+
+func secureInputLinux(ext bool) *crutils.SecureBuffer {
 	//fmt.Println("SecureInput version 32")
 	exec.Command("stty", "-F", "/dev/tty", "cbreak", "min", "1").Run() // disable input buffering
 	exec.Command("stty", "-F", "/dev/tty", "-echo").Run()              // do not display entered characters on the screen
@@ -145,14 +192,62 @@ func SecureInputTest() []byte {
 	defer exec.Command("stty", "-F", "/dev/tty", "icanon").Run()
 	var b []byte = make([]byte, 1)
 	for b[0] != byte(1) { // Ctrl + a
-		os.Stdin.Read(b)
+		input.Read(b)
 		fmt.Println("I got the byte", b, "("+string(b)+")")
 	}
 	return []byte("test finished")
 }
 
+// reads the password without echo directly into the locked memory, the result remains locked.
+// unlike shell.ReadPassword(), the input buffer is never reallocated without annihilation (leaving the copies behind).
+func readPassword() (*crutils.SecureBuffer, error) {
+	fd := int(input.Fd())
+	state, err := shell.MakeRaw(fd)
+	if err != nil {
+		return nil, err
+	}
+	defer shell.Restore(fd, state)
+
+	buf := crutils.NewSecureBuffer(inputBufferSize)
+	defer func() { buf.Destroy() }()
+	b := make([]byte, 1)
+	n := 0
+	for {
+		if _, err = input.Read(b); err != nil {
+			return nil, err
+		}
+		switch b[0] {
+		case '\r', '\n':
+			b[0] = 0
+			return crutils.NewSecureBufferFrom(buf.Bytes()[:n]), nil
+		case 3: // Ctrl+C
+			return nil, errors.New("interrupted")
+		case 4: // Ctrl+D
+			if n == 0 {
+				return nil, io.EOF
+			}
+		case 8, 127: // backspace
+			if n > 0 {
+				n--
+				buf.Bytes()[n] = 0
+			}
+		default:
+			if n == buf.Len() {
+				buf = growBuffer(buf)
+			}
+			buf.Bytes()[n] = b[0]
+			n++
+		}
+	}
+}
+
 func PasswordModeInput() []byte {
-	s, err := shell.ReadPassword(int(syscall.Stdin))
+	return unlock(PasswordModeInputLocked())
+}
+
+// don't forget to destroy the result!
+func PasswordModeInputLocked() *crutils.SecureBuffer {
+	s, err := readPassword()
 	fmt.Println()
 	if err != nil {
 		fmt.Printf(">>>>>> Input Error: %s \n", err)
@@ -178,9 +273,14 @@ func PlainTextInput() []byte {
 }
 
 func SecureInput(ext bool) []byte {
+	return unlock(SecureInputLocked(ext))
+}
+
+// the keys and passwords should never leave the locked memory; don't forget to destroy the result!
+func SecureInputLocked(ext bool) *crutils.SecureBuffer {
 	if runtime.GOOS == "linux" {
-		return SecureInputLinux(ext)
+		return secureInputLinux(ext)
 	} else {
-		return PasswordModeInput()
+		return PasswordModeInputLocked()
 	}
 }